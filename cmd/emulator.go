@@ -0,0 +1,32 @@
+//go:build emulator
+// +build emulator
+
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package main
+
+import (
+	"os"
+	"rpc/internal/emulator"
+	"rpc/pkg/utils"
+)
+
+// the emulator is only built in with the emulator tag, so release builds never answer AMT commands from a fixture
+func init() {
+	setupEmulator = func() error {
+		fixture := os.Getenv("RPC_EMULATOR")
+		if fixture == "" {
+			return nil
+		}
+		emulated, err := emulator.Install(fixture)
+		if err != nil {
+			return err
+		}
+		initiateLMS = func() error {
+			return emulated.ServeLMS(utils.LMSAddress + ":" + utils.LMSPort)
+		}
+		return nil
+	}
+}
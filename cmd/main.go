@@ -5,37 +5,153 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"rpc/internal/amt"
 	"rpc/internal/lms"
+	"rpc/internal/local"
 	"rpc/internal/rpc"
 	"rpc/internal/rps"
+	"rpc/pkg/heci"
+	"rpc/pkg/pthi"
 	"rpc/pkg/utils"
+	"sync"
 	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// accessExitCodes are the exit codes for each classified failure to access the MEI device
+var accessExitCodes = map[heci.AccessFailure]int{
+	heci.AccessFailed:   utils.ExitAMTUnavailable,
+	heci.AccessNoDevice: utils.ExitMEIDeviceMissing,
+	heci.AccessNoDriver: utils.ExitMEIDriverMissing,
+	heci.AccessDenied:   utils.ExitMEIAccessDenied,
+	heci.AccessNoAMTHI:  utils.ExitAMTHIMissing,
+	heci.AccessBusy:     utils.ExitMEIBusy,
+}
+
+// setupEmulator is set in builds with the emulator tag, where it points rpc at the device in RPC_EMULATOR
+var setupEmulator func() error
+
+// initiateLMS starts MicroLMS, or serves LMS from the emulator when rpc runs against one
+var initiateLMS = amt.Command{}.InitiateLMS
+
+// cleanups run before rpc exits, whether main returns, exits with a status or logs a fatal error
+var cleanups []func()
+var cleanupLock sync.Mutex
+
+// addCleanup registers cleanup to run before rpc exits
+func addCleanup(cleanup func()) {
+	cleanupLock.Lock()
+	defer cleanupLock.Unlock()
+	cleanups = append(cleanups, cleanup)
+}
+
+// runCleanups runs the cleanups in the reverse order they were added
+func runCleanups() {
+	cleanupLock.Lock()
+	defer cleanupLock.Unlock()
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
+// exit runs the cleanups and exits with code
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
 func checkAccess() {
 	amt := amt.Command{}
 	result, err := amt.Initialize()
 	if !result || err != nil {
+		accessErr := &heci.AccessError{}
+		if errors.As(err, &accessErr) {
+			println("Unable to launch application, " + accessErr.Error())
+			println(accessErr.Remediation())
+			exit(accessExitCodes[accessErr.Failure])
+		}
 		println("Unable to launch application. Please ensure that Intel ME is present, the MEI driver is installed and that this application is run with administrator or root privileges.")
-		os.Exit(1)
+		exit(utils.ExitAMTUnavailable)
 	}
 }
-func main() {
 
-	checkAccess()
-	//process flags
-	flags := rpc.NewFlags(os.Args)
-	_, result := flags.ParseFlags()
+// setupHECI replays HECI messages from a trace or records them to one as the global flags ask
+func setupHECI(global rpc.GlobalFlags) error {
+	if global.HECIReplay != "" {
+		replay, err := heci.LoadReplay(global.HECIReplay)
+		if err != nil {
+			return err
+		}
+		heci.NewTransport = func() heci.Transport {
+			return replay
+		}
+	}
+	if global.HECITrace != "" {
+		file, err := os.Create(global.HECITrace)
+		if err != nil {
+			return err
+		}
+		addCleanup(func() {
+			file.Sync()
+			file.Close()
+		})
+		tracer := heci.NewTracer(file, heci.PTHIClientGUID)
+		tracer.Redact = pthi.RedactSecrets
+		newTransport := heci.NewTransport
+		heci.NewTransport = func() heci.Transport {
+			return tracer.Wrap(newTransport())
+		}
+	}
+	return nil
+}
+func main() {
+	defer runCleanups()
+	log.RegisterExitHandler(runCleanups)
+	// an interrupt cancels HECI reads and prompts in progress and closes the RPS connection, so that
+	// the command fails and cleans up; a second interrupt ends rpc at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	addCleanup(stop)
+	addCleanup(heci.DefaultSession.Close)
+	heci.ReadContext = ctx
+	rpc.PromptContext = ctx
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	global, args, err := rpc.ParseGlobalFlags(os.Args)
+	if err != nil {
+		println(err.Error())
+		exit(1)
+	}
+	if setupEmulator != nil {
+		err = setupEmulator()
+		if err != nil {
+			println("Unable to load the AMT emulator: " + err.Error())
+			exit(1)
+		}
+	}
+	heci.MEIDevicePath = global.MEIDevice
+	heci.ReadTimeout = global.HECITimeout
+	err = setupHECI(global)
+	if err != nil {
+		println("Unable to set up HECI tracing: " + err.Error())
+		exit(1)
+	}
+	//process flags, each command checks access once its flags are parsed, before prompting for input
+	flags := rpc.NewFlags(args)
+	flags.AccessCheck = checkAccess
+	command, result := flags.ParseFlags()
 	if !result {
-		os.Exit(1)
+		exit(1)
 	}
 	if flags.SyncClock {
 		fmt.Println("Time to sync the clock")
@@ -46,10 +162,29 @@ func main() {
 		log.SetLevel(log.InfoLevel)
 	}
 
+	if flags.Local {
+		err := local.NewLocalConfiguration(*flags, amt.Command{}).Run(command)
+		if err != nil {
+			log.Error(err.Error())
+			if errors.Is(err, heci.ErrDeviceBusy) {
+				exit(utils.ExitMEIBusy)
+			}
+			exit(1)
+		}
+		return
+	}
+
 	//create activation request
 	payload := rps.Payload{
 		AMT: amt.Command{},
 	}
+	if command == "activate" {
+		err := payload.ActivationPreflight()
+		if err != nil {
+			log.Error(err.Error())
+			exit(1)
+		}
+	}
 	messageRequest, err := payload.CreateMessageRequest(*flags)
 	if err != nil {
 		log.Fatal(err)
@@ -59,10 +194,12 @@ func main() {
 	log.Trace("Seeing if existing LMS is already running....")
 	lms := lms.LMSConnection{}
 	err = lms.Connect(utils.LMSAddress, utils.LMSPort)
-	amt := amt.Command{}
+	lmsErr := make(chan error, 1)
 	if err != nil {
 		log.Trace("nope!\n")
-		go amt.InitiateLMS()
+		go func() {
+			lmsErr <- initiateLMS()
+		}()
 	} else {
 		log.Trace("yes!\n")
 	}
@@ -70,8 +207,15 @@ func main() {
 	if err != nil {
 		log.Println(err)
 	}
-	// Calling Sleep method
-	time.Sleep(5 * time.Second)
+	// give LMS time to start, bailing out if it fails to
+	select {
+	case err = <-lmsErr:
+		log.Error(err.Error())
+		exit(1)
+	case <-time.After(5 * time.Second):
+	case <-ctx.Done():
+		exit(1)
+	}
 
 	log.Trace("done\n")
 	amtactivationserver := rps.AMTActivationServer{
@@ -82,12 +226,11 @@ func main() {
 	if err != nil {
 		log.Error("error connecting to RPS")
 		log.Error(err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
 	log.Debug("listening to RPS...")
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	interrupt := ctx.Done()
 	rpsDataChannel := amtactivationserver.Listen()
 
 	log.Debug("sending activation request to RPS")
@@ -158,6 +301,8 @@ func main() {
 
 		case <-interrupt:
 			log.Info("interrupt")
+			// the loop ends once RPS sees the connection close, stop selecting the done context meanwhile
+			interrupt = nil
 
 			// Cleanly close the connection by sending a close message and then
 			// waiting (with timeout) for the server to close the connection.
@@ -7,4 +7,5 @@ require (
 	github.com/sirupsen/logrus v1.7.0
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/sys v0.0.0-20210514084401-e8d321eab015
+	golang.org/x/term v0.0.0-20210503060354-a79de5458b56
 )
@@ -4,69 +4,33 @@
  **********************************************************************/
 package amt
 
-// #cgo linux CFLAGS: -g -Wno-error -Wformat -Wformat-security -D_POSIX -DBUILD_LIBRARY -D_FORTIFY_SOURCE=2 -fstack-protector-strong
-// #cgo windows CFLAGS: -g -w -DMICROSTACK_NO_STDAFX -DWIN32 -DWIN64 -DNDEBUG -D_CONSOLE -DMICROSTACK_NO_STDAFX -DWINSOCK2 -DMICROSTACK_NOTLS -D_UNICODE -D_WINDOWS -D_WIN32_WINNT=0x0A00 -DBUILD_LIBRARY
-// #cgo windows LDFLAGS: -lDbgHelp -lIphlpapi -lSetupapi -lws2_32 -lPsapi -lCrypt32 -lWintrust -lVersion -lWtsapi32 -lGdiplus -lUserenv -lgdi32 -lucrtbase
-// #include "../../microlms/MicroLMS/main.c"
-// #include "../../microlms/core/utils.c"
-// #include "../../microlms/heci/HECIWin.c"
-// #include "../../microlms/heci/HECILinux.c"
-// #include "../../microlms/heci/LMEConnection.c"
-// #include "../../microlms/heci/PTHICommand.c"
-// #include "../../microlms/microstack/ILibAsyncServerSocket.c"
-// #include "../../microlms/microstack/ILibAsyncSocket.c"
-// #include "../../microlms/microstack/ILibLMS.c"
-// #include "../../microlms/microstack/ILibParsers.c"
-import "C"
 import (
 	"bytes"
-	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"os"
+	"rpc/pkg/heci"
 	"rpc/pkg/pthi"
 	"rpc/pkg/utils"
-	"strconv"
 	"strings"
-	"unsafe"
 )
 
-//TODO: Ensure pointers are freed properly throughout this file
-
-// AMTUnicodeString ...
-type AMTUnicodeString struct {
-	Length uint16
-	String [20]uint8 //[UNICODE_STRING_LEN]
-}
-
-// AMTVersionType ...
-type AMTVersionType struct {
-	Description AMTUnicodeString
-	Version     AMTUnicodeString
-}
-
-// CodeVersions ...
-type CodeVersions struct {
-	BiosVersion   [65]uint8 //[BIOS_VERSION_LEN]
-	VersionsCount uint32
-	Versions      [50]AMTVersionType //[VERSIONS_NUMBER]
-}
-
-// AMTANSIString ...
-type AMTANSIString struct {
-	Length uint16
-	Buffer [1000]C.char
-}
-
 // InterfaceSettings ...
 type InterfaceSettings struct {
 	IsEnabled   bool
 	LinkStatus  string
 	DHCPEnabled bool
 	DHCPMode    string
-	IPAddress   string //net.IP
-	MACAddress  string
+	IPAddress   net.IP
+	MACAddress  net.HardwareAddr
+}
+
+// MACAddresses holds the MAC address dedicated to AMT and the one it shares with the host
+type MACAddresses struct {
+	DedicatedMAC net.HardwareAddr
+	HostMAC      net.HardwareAddr
 }
 
 // RemoteAccessStatus holds connect status information
@@ -77,17 +41,9 @@ type RemoteAccessStatus struct {
 	MPSHostname   string
 }
 
-// CCertHashEntry is used for reading data from the C call for Cert Hashes
-type CCertHashEntry struct {
-	CertificateHash [64]uint8
-	HashAlgorithm   uint8
-	IsActive        uint32
-	IsDefault       uint32
-	Name            AMTANSIString
-}
-
 // CertHashEntry is the GO struct for holding Cert Hash Entries
 type CertHashEntry struct {
+	Handle    uint32
 	Hash      string
 	Name      string
 	Algorithm string
@@ -95,12 +51,40 @@ type CertHashEntry struct {
 	IsDefault bool
 }
 
+// FQDN holds the host FQDN stored in AMT and whether it is shared with the OS
+type FQDN struct {
+	FQDN               string
+	Shared             bool
+	DDNSUpdateEnabled  bool
+	DDNSTTL            uint32
+	DDNSUpdateInterval uint32
+}
+
+// FeaturesState holds whether SOL and IDER sessions are open and whether system defense and the
+// web UI are enabled. GET_FEATURES_STATE does not report whether redirection or KVM are enabled.
+type FeaturesState struct {
+	SOLOpen                bool
+	IDEROpen               bool
+	SystemDefenseActivated bool
+	WebUIEnabled           bool
+}
+
 // LocalSystemAccount holds username and password
 type LocalSystemAccount struct {
 	Username string
 	Password string
 }
 
+// String keeps the password out of logs and formatted output
+func (lsa LocalSystemAccount) String() string {
+	return "{" + lsa.Username + " ********}"
+}
+
+// GoString keeps the password out of %#v output
+func (lsa LocalSystemAccount) GoString() string {
+	return lsa.String()
+}
+
 type AMT interface {
 	Initialize() (bool, error)
 	GetVersionDataFromME(key string) (string, error)
@@ -108,23 +92,51 @@ type AMT interface {
 	GetUUIDV2() (string, error)
 	GetControlMode() (int, error)
 	GetControlModeV2() (int, error)
+	GetProvisioningState() (int, error)
+	GetProvisioningMode() (int, error)
+	GetProvisioningTLSMode() (int, error)
+	GetZeroTouchEnabled() (bool, error)
+	GetEHBCEnabled() (bool, error)
+	GetFeaturesState() (FeaturesState, error)
+	GetLastHostResetReason() (int, error)
+	GetCurrentPowerPolicy() (string, error)
 	GetOSDNSSuffix() (string, error)
+	GetOSIPAddress() (net.IP, error)
+	GetMACAddresses() (MACAddresses, error)
 	GetDNSSuffix() (string, error)
+	SetDNSSuffix(suffix string) error
+	GetDNSSuffixList() ([]string, error)
+	GetFQDN() (FQDN, error)
+	SetHostFQDN(fqdn string) error
 	GetCertificateHashes() ([]CertHashEntry, error)
 	GetRemoteAccessConnectionStatus() (RemoteAccessStatus, error)
+	OpenUserInitiatedConnection() error
+	CloseUserInitiatedConnection() error
 	GetLANInterfaceSettings(useWireless bool) (InterfaceSettings, error)
+	GetRNGSeedStatus() (int, error)
+	GenerateRNGSeed() error
+	SetEnterpriseAccess(hostIP net.IP, enterpriseAccess bool) error
 	GetLocalSystemAccount() (LocalSystemAccount, error)
-	InitiateLMS()
+	Unprovision() error
+	StartConfiguration() error
+	StopConfiguration() error
+	SetProvisioningServerOTP(otp string) error
+	InitiateLMS() error
 }
+
+// Command runs AMT commands over heci.NewTransport. On the MEI device its commands share the
+// connection of heci.DefaultSession, which callers must close once done with AMT.
 type Command struct {
 }
 
 // Initialize determines if rpc is able to initialize the heci driver
 func (amt Command) Initialize() (bool, error) {
 	// initialize HECI interface
-	result := C.heci_Init(nil, 0)
-	if *((*bool)(unsafe.Pointer(&result))) == false {
-		return false, errors.New("unable to initialize")
+	h := heci.NewTransport()
+	err := h.Init()
+	defer h.Close()
+	if err != nil {
+		return false, fmt.Errorf("unable to initialize: %w", err)
 	}
 
 	return true, nil
@@ -132,28 +144,16 @@ func (amt Command) Initialize() (bool, error) {
 
 // GetVersionDataFromME ...
 func (amt Command) GetVersionDataFromME(key string) (string, error) {
-
-	_, err := amt.Initialize()
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	codeVersions, err := pthi.GetCodeVersions()
 	if err != nil {
 		return "", err
 	}
-	//get code version
-	codeVersion := CodeVersions{}
-	packedCodeVersion := C.struct__CODE_VERSIONS{}
-	status := C.pthi_GetCodeVersions(&packedCodeVersion)
-
-	// additional versions
-	if status == 0 {
-		cdata := C.GoBytes(unsafe.Pointer(&packedCodeVersion), C.sizeof_struct__CODE_VERSIONS)
-		buf := bytes.NewBuffer(cdata)
-		binary.Read(buf, binary.LittleEndian, &codeVersion.BiosVersion)
-		binary.Read(buf, binary.LittleEndian, &codeVersion.VersionsCount)
-		binary.Read(buf, binary.LittleEndian, &codeVersion.Versions)
-
-		for i := 0; i < int(codeVersion.VersionsCount); i++ {
-			if string(codeVersion.Versions[i].Description.String[:codeVersion.Versions[i].Description.Length]) == key {
-				return strings.Replace(string(codeVersion.Versions[i].Version.String[:]), "\u0000", "", -1), nil
-			}
+
+	for _, v := range codeVersions.Versions {
+		if v.Description == key {
+			return v.Version, nil
 		}
 	}
 
@@ -162,31 +162,7 @@ func (amt Command) GetVersionDataFromME(key string) (string, error) {
 
 // GetUUID ...
 func (amt Command) GetUUID() (string, error) {
-	_, err := amt.Initialize()
-	if err != nil {
-		return "", err
-	}
-	//get code version
-	//codeVersion := CodeVersions{}
-	packedUUID := C.AMT_UUID{}
-	status := C.pthi_GetUUID(&packedUUID)
-
-	var hexValues [16]string
-
-	if status == 0 {
-		for i := 0; i < 16; i++ {
-			hexValues[i] = fmt.Sprintf("%02x", int(packedUUID[i]))
-		}
-
-		uuidStr := hexValues[3] + hexValues[2] + hexValues[1] + hexValues[0] + "-" +
-			hexValues[5] + hexValues[4] + "-" +
-			hexValues[7] + hexValues[6] + "-" +
-			hexValues[8] + hexValues[9] + "-" +
-			hexValues[10] + hexValues[11] + hexValues[12] + hexValues[13] + hexValues[14] + hexValues[15]
-		return uuidStr, nil
-
-	}
-	return "", errors.New("UUID not found")
+	return amt.GetUUIDV2()
 }
 
 // GetUUID ...
@@ -215,247 +191,371 @@ func (amt Command) GetUUIDV2() (string, error) {
 
 // GetControlMode ...
 func (amt Command) GetControlMode() (int, error) {
-	_, err := amt.Initialize()
+	return amt.GetControlModeV2()
+}
+
+// GetControlMode ...
+func (amt Command) GetControlModeV2() (int, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetControlMode()
 	if err != nil {
 		return -1, err
 	}
 
-	var controlMode C.int = 0
-	status := C.pthi_GetControlMode(&controlMode)
-	if status == 0 {
-		return int(controlMode), nil
+	return result, nil
+
+}
+
+// GetProvisioningState returns whether AMT is in pre, in or post provisioning state
+func (amt Command) GetProvisioningState() (int, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetProvisioningState()
+	if err != nil {
+		return -1, err
 	}
-	return -1, errors.New("unable to determine control mode")
+	return int(result), nil
 }
 
-// GetControlMode ...
-func (amt Command) GetControlModeV2() (int, error) {
+// GetProvisioningMode returns the mode AMT was provisioned in
+func (amt Command) GetProvisioningMode() (int, error) {
 	pthi := pthi.NewPTHICommand()
 	defer pthi.Close()
-	result, err := pthi.GetControlMode()
+	result, err := pthi.GetProvisioningMode()
 	if err != nil {
 		return -1, err
 	}
+	return int(result.Mode), nil
+}
 
-	return result, nil
+// GetProvisioningTLSMode returns whether remote configuration uses PKI or PSK
+func (amt Command) GetProvisioningTLSMode() (int, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetProvisioningTLSMode()
+	if err != nil {
+		return -1, err
+	}
+	return int(result), nil
+}
+
+// GetZeroTouchEnabled returns whether zero touch remote configuration is enabled
+func (amt Command) GetZeroTouchEnabled() (bool, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GetZeroTouchEnabled()
+}
 
+// GetEHBCEnabled returns whether embedded host based configuration is allowed
+func (amt Command) GetEHBCEnabled() (bool, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GetEHBCState()
 }
 
-// GetDNSSuffix ...
+// GetFeaturesState returns the SOL and IDER session state and the system defense and web UI state
+func (amt Command) GetFeaturesState() (FeaturesState, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetFeaturesState()
+	if err != nil {
+		return FeaturesState{}, err
+	}
+	return FeaturesState{
+		SOLOpen:                result.SOLOpen,
+		IDEROpen:               result.IDEROpen,
+		SystemDefenseActivated: result.SystemDefenseActivated,
+		WebUIEnabled:           result.WebUIEnabled,
+	}, nil
+}
+
+// GetLastHostResetReason returns whether the host was last reset remotely through AMT or by other means
+func (amt Command) GetLastHostResetReason() (int, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetLastHostResetReason()
+	if err != nil {
+		return -1, err
+	}
+	return int(result.Reason), nil
+}
+
+// GetCurrentPowerPolicy returns the name of the active power package
+func (amt Command) GetCurrentPowerPolicy() (string, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GetCurrentPowerPolicy()
+}
+
+// GetOSDNSSuffix looks up the DNS suffix of the host interface that AMT is bound to
 func (amt Command) GetOSDNSSuffix() (string, error) {
+	ip, _ := amt.GetOSIPAddress()
+	if ip == nil {
+		return "", nil
+	}
+	suffix, _ := net.LookupAddr(ip.String())
+	if len(suffix) > 0 {
+		hostname, _ := os.Hostname()
+		dnsSuffix := strings.Trim(suffix[0], hostname)
+		dnsSuffix = strings.TrimLeft(dnsSuffix, ".")
+		dnsSuffix = strings.TrimRight(dnsSuffix, ".")
+		return dnsSuffix, nil
+	}
+	return "", nil
+}
+
+// GetOSIPAddress returns the IPv4 address of the host interface that AMT is bound to, or nil
+// when no host interface matches one of the AMT MAC addresses
+func (amt Command) GetOSIPAddress() (net.IP, error) {
+	candidates := []net.HardwareAddr{}
+	macAddresses, err := amt.GetMACAddresses()
+	if err == nil {
+		candidates = append(candidates, macAddresses.HostMAC, macAddresses.DedicatedMAC)
+	}
 	lanResult, _ := amt.GetLANInterfaceSettings(false)
+	candidates = append(candidates, lanResult.MACAddress)
 	ifaces, _ := net.Interfaces()
-	for _, v := range ifaces {
-		if v.HardwareAddr.String() == lanResult.MACAddress {
-			addrs, _ := v.Addrs()
-			for _, a := range addrs {
-				networkIp, ok := a.(*net.IPNet)
-				if ok && !networkIp.IP.IsLoopback() && networkIp.IP.To4() != nil {
-					ip := networkIp.IP.String()
-					suffix, _ := net.LookupAddr(ip)
-					if len(suffix) > 0 {
-						hostname, _ := os.Hostname()
-						dnsSuffix := strings.Trim(suffix[0], hostname)
-						dnsSuffix = strings.TrimLeft(dnsSuffix, ".")
-						dnsSuffix = strings.TrimRight(dnsSuffix, ".")
-						return dnsSuffix, nil
-					}
-					return "", nil
-				}
+	v, ok := hostInterface(candidates, ifaces)
+	if !ok {
+		return nil, nil
+	}
+	addrs, _ := v.Addrs()
+	for _, a := range addrs {
+		networkIp, ok := a.(*net.IPNet)
+		if ok && !networkIp.IP.IsLoopback() && networkIp.IP.To4() != nil {
+			return networkIp.IP, nil
+		}
+	}
+	return nil, nil
+}
+
+// hostInterface returns the first host interface whose hardware address matches one of the
+// candidate MAC addresses, skipping unset addresses
+func hostInterface(candidates []net.HardwareAddr, ifaces []net.Interface) (net.Interface, bool) {
+	for _, mac := range candidates {
+		if len(mac) == 0 || bytes.Equal(mac, make(net.HardwareAddr, len(mac))) {
+			continue
+		}
+		for _, v := range ifaces {
+			if bytes.Equal(v.HardwareAddr, mac) {
+				return v, true
 			}
 		}
 	}
-	return "", nil
+	return net.Interface{}, false
 }
 
-// GetDNSSuffix ...
-func (amt Command) GetDNSSuffix() (string, error) {
-	_, err := amt.Initialize()
+// GetMACAddresses returns the MAC address dedicated to AMT and the MAC address of the host interface
+func (amt Command) GetMACAddresses() (MACAddresses, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetMACAddresses()
 	if err != nil {
-		return "", err
+		return MACAddresses{}, err
 	}
-	dnsSuffix := AMTANSIString{}
-	packedDNSSuffix := C.struct__AMT_ANSI_STRING{}
-	status := C.pthi_GetDnsSuffix(&packedDNSSuffix)
-	if status == 0 {
-
-		cdata := C.GoBytes(unsafe.Pointer(&packedDNSSuffix), C.sizeof_struct__AMT_ANSI_STRING*253) //253 is maximum FQDN Length
-		buf := bytes.NewBuffer(cdata)
-
-		binary.Read(buf, binary.LittleEndian, &dnsSuffix.Length)
-		binary.Read(buf, binary.LittleEndian, &dnsSuffix.Buffer)
-		cStrings := (*[1 << 28]*C.char)(unsafe.Pointer(&dnsSuffix.Buffer))[:int(dnsSuffix.Length):int(dnsSuffix.Length)]
-		if len(cStrings) > 0 {
-			return C.GoString(cStrings[0])[:int(dnsSuffix.Length)], nil
-		}
-		return "", nil
+	return MACAddresses{
+		DedicatedMAC: result.DedicatedMAC,
+		HostMAC:      result.HostMAC,
+	}, nil
+}
+
+// GetDNSSuffix returns the PKI DNS suffix stored in AMT
+func (amt Command) GetDNSSuffix() (string, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GetDNSSuffix()
+}
+
+// SetDNSSuffix sets the PKI DNS suffix stored in AMT, only allowed in pre-provisioning state
+func (amt Command) SetDNSSuffix(suffix string) error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.SetDNSSuffix(suffix)
+}
+
+// GetDNSSuffixList returns the full list of DNS suffixes stored in AMT
+func (amt Command) GetDNSSuffixList() ([]string, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GetDNSSuffixList()
+}
+
+// GetFQDN returns the host FQDN stored in AMT along with its shared and dynamic DNS settings
+func (amt Command) GetFQDN() (FQDN, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetFQDN()
+	if err != nil {
+		return FQDN{}, err
 	}
-	return "", errors.New("unable to retrieve DNS suffix")
+	return FQDN{
+		FQDN:               result.FQDN,
+		Shared:             result.SharedFQDN,
+		DDNSUpdateEnabled:  result.DDNSUpdateEnabled,
+		DDNSTTL:            result.DDNSTTL,
+		DDNSUpdateInterval: result.DDNSPeriodicUpdateInterval,
+	}, nil
 }
 
-// GetCertificateHashes ...
+// SetHostFQDN sets the host FQDN stored in AMT
+func (amt Command) SetHostFQDN(fqdn string) error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.SetHostFQDN(fqdn)
+}
+
+// GetCertificateHashes returns every certificate hash entry, including inactive ones
 func (amt Command) GetCertificateHashes() ([]CertHashEntry, error) {
 	hashEntries := []CertHashEntry{}
 
-	_, err := amt.Initialize()
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	handles, err := pthi.EnumerateHashHandles()
 	if err != nil {
 		return hashEntries, err
 	}
-	hashedHandles := C.struct__AMT_HASH_HANDLES{}
-	packedCertHashEntry := C.struct__CERTHASH_ENTRY{}
-	status := C.pthi_EnumerateHashHandles(&hashedHandles)
-	if status == 0 {
-		for i := 0; i < int(hashedHandles.Length); i++ {
-			status2 := C.pthi_GetCertificateHashEntry(hashedHandles.Handles[i], &packedCertHashEntry)
-			tmp := CertHashEntry{}
-			ccerthash := CCertHashEntry{}
-			hashSize := 0
-			if status2 == 0 {
-				cdata := C.GoBytes(unsafe.Pointer(&packedCertHashEntry), C.sizeof_struct__CERTHASH_ENTRY+(1024))
-				buf := bytes.NewBuffer(cdata)
-
-				binary.Read(buf, binary.LittleEndian, &ccerthash.IsDefault)
-				binary.Read(buf, binary.LittleEndian, &ccerthash.IsActive)
-				binary.Read(buf, binary.LittleEndian, &ccerthash.CertificateHash)
-				binary.Read(buf, binary.LittleEndian, &ccerthash.HashAlgorithm)
-				binary.Read(buf, binary.LittleEndian, &ccerthash.Name)
-
-				hashSize, tmp.Algorithm = utils.InterpretHashAlgorithm(int(ccerthash.HashAlgorithm))
-				if ccerthash.IsActive == 1 {
-					cStrings := (*[1 << 28]*C.char)(unsafe.Pointer(&ccerthash.Name.Buffer))[:int(ccerthash.Name.Length):int(ccerthash.Name.Length)]
-					if len(cStrings) > 0 {
-
-						tmp.Name = strings.Trim(C.GoString(cStrings[0])[:int(ccerthash.Name.Length)], "\xab")
-					}
-					tmp.IsDefault = ccerthash.IsDefault == 1
-					tmp.IsActive = ccerthash.IsActive == 1
-
-					hashString := ""
-					for i := 0; i < hashSize; i++ {
-						hashString = hashString + fmt.Sprintf("%02x", int(ccerthash.CertificateHash[i]))
-					}
-
-					tmp.Hash = hashString
-					hashEntries = append(hashEntries, tmp)
-				}
-
-			} else {
-				//todo: log error
-			}
+	for _, handle := range handles {
+		entry, err := pthi.GetCertHashEntry(handle)
+		if err != nil {
+			return hashEntries, err
 		}
-		return hashEntries, nil
+		hashSize, algorithm := utils.InterpretHashAlgorithm(int(entry.HashAlgorithm))
+		hashEntries = append(hashEntries, CertHashEntry{
+			Handle:    handle,
+			Hash:      hex.EncodeToString(entry.CertificateHash[:hashSize]),
+			Name:      entry.Name,
+			Algorithm: algorithm,
+			IsActive:  entry.IsActive == 1,
+			IsDefault: entry.IsDefault == 1,
+		})
 	}
-	return hashEntries, errors.New("unable to retrieve certificate hashes")
+	return hashEntries, nil
 }
 
 // GetRemoteAccessConnectionStatus ...
 func (amt Command) GetRemoteAccessConnectionStatus() (RemoteAccessStatus, error) {
-	remoteAccessStatus := RemoteAccessStatus{}
-
-	_, err := amt.Initialize()
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetRemoteAccessConnectionStatus()
 	if err != nil {
-		return remoteAccessStatus, err
-	}
-	mpsHostname := AMTANSIString{}
-	packedRAS := C.struct__REMOTE_ACCESS_STATUS{}
-	status := C.pthi_GetRemoteAccessConnectionStatus(&packedRAS)
-	if status == 0 {
-		remoteAccessStatus.NetworkStatus = utils.InterpretAMTNetworkConnectionStatus(int(packedRAS.AmtNetworkConnectionStatus))
-		remoteAccessStatus.RemoteStatus = utils.InterpretRemoteAccessConnectionStatus(int(packedRAS.RemoteAccessConnectionStatus))
-		remoteAccessStatus.RemoteTrigger = utils.InterpretRemoteAccessTrigger(int(packedRAS.RemoteAccessConnectionTrigger))
-
-		cdata := C.GoBytes(unsafe.Pointer(&packedRAS.MpsHostname), C.sizeof_struct__AMT_ANSI_STRING*utils.MPSServerMaxLength)
-		buf := bytes.NewBuffer(cdata)
-
-		binary.Read(buf, binary.LittleEndian, &mpsHostname.Length)
-		binary.Read(buf, binary.LittleEndian, &mpsHostname.Buffer)
-		cStrings := (*[1 << 28]*C.char)(unsafe.Pointer(&mpsHostname.Buffer))[:int(mpsHostname.Length):int(mpsHostname.Length)]
-		if len(cStrings) > 0 {
-			remoteAccessStatus.MPSHostname = strings.Trim(C.GoString(cStrings[0]), "\xab")
-		}
-	} else {
-		return remoteAccessStatus, nil
+		return RemoteAccessStatus{}, err
 	}
-	return remoteAccessStatus, errors.New("unable to retrieve remote access connection status")
+	return RemoteAccessStatus{
+		NetworkStatus: utils.InterpretAMTNetworkConnectionStatus(int(result.NetworkConnectionStatus)),
+		RemoteStatus:  utils.InterpretRemoteAccessConnectionStatus(int(result.RemoteAccessConnectionStatus)),
+		RemoteTrigger: utils.InterpretRemoteAccessTrigger(int(result.RemoteAccessConnectionTrigger)),
+		MPSHostname:   result.MPSHostname,
+	}, nil
+}
+
+// OpenUserInitiatedConnection asks AMT to open a CIRA connection to the configured MPS
+func (amt Command) OpenUserInitiatedConnection() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.OpenUserInitiatedConnection()
+}
+
+// CloseUserInitiatedConnection asks AMT to close a user initiated CIRA connection
+func (amt Command) CloseUserInitiatedConnection() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.CloseUserInitiatedConnection()
 }
 
 // GetLANInterfaceSettings ...
 func (amt Command) GetLANInterfaceSettings(useWireless bool) (InterfaceSettings, error) {
-	interfaceSettings := InterfaceSettings{}
-
-	_, err := amt.Initialize()
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetLANInterfaceSettings(useWireless)
 	if err != nil {
-		return interfaceSettings, err
+		return InterfaceSettings{}, err
+	}
+	interfaceSettings := InterfaceSettings{
+		IsEnabled:   result.IsEnabled,
+		DHCPEnabled: result.DHCPEnabled,
+		IPAddress:   result.IPAddress,
+		MACAddress:  result.MACAddress,
 	}
 
-	LANSettings := C.struct__LAN_SETTINGS{}
-	var status C.uint
-	if useWireless {
-		status = C.pthi_GetLanInterfaceSettings(1, &LANSettings)
+	if result.DHCPMode == 1 {
+		interfaceSettings.DHCPMode = "active"
 	} else {
-		status = C.pthi_GetLanInterfaceSettings(0, &LANSettings)
-
+		interfaceSettings.DHCPMode = "passive"
 	}
-	if status == 0 {
-		interfaceSettings.IsEnabled = LANSettings.Enabled == 1
-		interfaceSettings.DHCPEnabled = LANSettings.DhcpEnabled == 1
-
-		if LANSettings.DhcpIpMode == 1 {
-			interfaceSettings.DHCPMode = "active"
-		} else {
-			interfaceSettings.DHCPMode = "passive"
-		}
-
-		if LANSettings.LinkStatus == 1 {
-			interfaceSettings.LinkStatus = "up"
-		} else {
-			interfaceSettings.LinkStatus = "down"
-		}
-
-		part1 := LANSettings.Ipv4Address >> 24 & 0xff
-		part2 := LANSettings.Ipv4Address >> 16 & 0xff
-		part3 := LANSettings.Ipv4Address >> 8 & 0xff
-		part4 := LANSettings.Ipv4Address & 0xff
 
-		interfaceSettings.IPAddress = strconv.Itoa(int(part1)) + "." + strconv.Itoa(int(part2)) + "." + strconv.Itoa(int(part3)) + "." + strconv.Itoa(int(part4))
-
-		macPart0 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[0]))
-		macPart1 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[1]))
-		macPart2 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[2]))
-		macPart3 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[3]))
-		macPart4 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[4]))
-		macPart5 := fmt.Sprintf("%02x", int(LANSettings.MacAddress[5]))
-		interfaceSettings.MACAddress = macPart0 + ":" + macPart1 + ":" + macPart2 + ":" + macPart3 + ":" + macPart4 + ":" + macPart5
+	if result.LinkStatus == 1 {
+		interfaceSettings.LinkStatus = "up"
 	} else {
-		return interfaceSettings, errors.New("unable to retrieve interface settings")
+		interfaceSettings.LinkStatus = "down"
 	}
 	return interfaceSettings, nil
 }
 
-// GetLocalSystemAccount ...
-func (amt Command) GetLocalSystemAccount() (LocalSystemAccount, error) {
-	lsa := LocalSystemAccount{}
-
-	_, err := amt.Initialize()
+// GetRNGSeedStatus returns whether the RNG seed AMT needs for TLS exists, is in progress or does not exist
+func (amt Command) GetRNGSeedStatus() (int, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetRNGSeedStatus()
 	if err != nil {
-		return lsa, err
+		return -1, err
 	}
+	return int(result), nil
+}
 
-	localSystemAccount := C.struct__LOCAL_SYSTEM_ACCOUNT{}
-	status := C.pthi_GetLocalSystemAccount(&localSystemAccount)
-	println(status)
-	//todo: should these be trimmed?
-	if status == 0 {
-		lsa.Username = strings.Replace(C.GoStringN((*C.char)(unsafe.Pointer(&localSystemAccount.username)), 33), "\u0000", "", -1) //33 from CFG_MAX_ACL_USER_LENGTH
-		lsa.Password = strings.Replace(C.GoStringN((*C.char)(unsafe.Pointer(&localSystemAccount.password)), 33), "\u0000", "", -1)
-	} else {
-		return lsa, errors.New("unable to retrieve local system account info")
+// GenerateRNGSeed asks AMT to generate its RNG seed
+func (amt Command) GenerateRNGSeed() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.GenerateRNGSeed()
+}
+
+// SetEnterpriseAccess tells AMT whether the host at hostIP has access to the enterprise network
+func (amt Command) SetEnterpriseAccess(hostIP net.IP, enterpriseAccess bool) error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.SetEnterpriseAccess(hostIP, enterpriseAccess)
+}
+
+// GetLocalSystemAccount returns the local system account used for host based activation. Only the
+// buffers pthi read the account into are wiped; the returned strings are copies that can't be.
+func (amt Command) GetLocalSystemAccount() (LocalSystemAccount, error) {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	result, err := pthi.GetLocalSystemAccount()
+	defer result.Wipe()
+	if err != nil {
+		return LocalSystemAccount{}, err
 	}
+	lsa := LocalSystemAccount{}
+	lsa.Username, lsa.Password = result.Credentials()
 	return lsa, nil
+}
 
+// Unprovision returns a device activated in client control mode to pre-provisioning state
+func (amt Command) Unprovision() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.Unprovision()
+}
+
+// StartConfiguration moves AMT into in-provisioning state
+func (amt Command) StartConfiguration() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.StartConfiguration()
 }
 
-// InitiateLMS ...
-func (amt Command) InitiateLMS() {
-	C.main_micro_lms()
+// StopConfiguration returns AMT from in-provisioning to pre-provisioning state
+func (amt Command) StopConfiguration() error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.StopConfiguration()
+}
+
+// SetProvisioningServerOTP sets the one time password a provisioning server must present
+func (amt Command) SetProvisioningServerOTP(otp string) error {
+	pthi := pthi.NewPTHICommand()
+	defer pthi.Close()
+	return pthi.SetProvisioningServerOTP(otp)
 }
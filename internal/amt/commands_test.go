@@ -26,3 +26,10 @@ func TestGetControlmode(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEqual(t, -1, result)
 }
+
+func TestGetVersionDataFromME(t *testing.T) {
+	amt := Command{}
+	result, err := amt.GetVersionDataFromME("AMT")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result)
+}
@@ -0,0 +1,41 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package amt
+
+import (
+	"os"
+	"strings"
+)
+
+// DNSSuffix returns override when set, otherwise the PKI DNS suffix stored in AMT,
+// falling back to the suffix of the OS interface bound to AMT
+func DNSSuffix(amt AMT, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	suffix, err := amt.GetDNSSuffix()
+	if suffix == "" {
+		suffix, _ = amt.GetOSDNSSuffix()
+	}
+	return suffix, err
+}
+
+// Hostname returns override when set, otherwise the hostname of the OS
+func Hostname(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	return os.Hostname()
+}
+
+// JoinFQDN appends dnsSuffix to hostname unless hostname is already qualified with it
+func JoinFQDN(hostname string, dnsSuffix string) string {
+	hostname = strings.TrimSuffix(hostname, ".")
+	dnsSuffix = strings.Trim(dnsSuffix, ".")
+	if dnsSuffix == "" || strings.HasSuffix(strings.ToLower(hostname), "."+strings.ToLower(dnsSuffix)) {
+		return hostname
+	}
+	return hostname + "." + dnsSuffix
+}
@@ -0,0 +1,29 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package amt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinFQDN(t *testing.T) {
+	assert.Equal(t, "host.example.com", JoinFQDN("host", "example.com"))
+}
+func TestJoinFQDNTrailingDots(t *testing.T) {
+	assert.Equal(t, "host.example.com", JoinFQDN("host.", ".example.com."))
+}
+func TestJoinFQDNAlreadyQualified(t *testing.T) {
+	assert.Equal(t, "host.Example.com", JoinFQDN("host.Example.com", "example.com"))
+}
+func TestJoinFQDNNoSuffix(t *testing.T) {
+	assert.Equal(t, "host", JoinFQDN("host", ""))
+}
+func TestHostnameOverride(t *testing.T) {
+	result, err := Hostname("override")
+	assert.NoError(t, err)
+	assert.Equal(t, "override", result)
+}
@@ -0,0 +1,34 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package amt
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ifaces = []net.Interface{
+	{Name: "lo"},
+	{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcd}},
+}
+
+func TestHostInterfaceMatchesHostMAC(t *testing.T) {
+	dedicated := net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}
+	host := net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcd}
+	result, ok := hostInterface([]net.HardwareAddr{dedicated, host}, ifaces)
+	assert.True(t, ok)
+	assert.Equal(t, "eth0", result.Name)
+}
+func TestHostInterfaceSkipsUnsetMAC(t *testing.T) {
+	unset := make(net.HardwareAddr, 6)
+	_, ok := hostInterface([]net.HardwareAddr{nil, unset}, append(ifaces, net.Interface{Name: "dummy", HardwareAddr: unset}))
+	assert.False(t, ok)
+}
+func TestHostInterfaceNoMatch(t *testing.T) {
+	_, ok := hostInterface([]net.HardwareAddr{{0x00, 0x1b, 0x21, 0x00, 0x00, 0x01}}, ifaces)
+	assert.False(t, ok)
+}
@@ -0,0 +1,30 @@
+//go:build cgo && !nocgo
+// +build cgo,!nocgo
+
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package amt
+
+// #cgo linux CFLAGS: -g -Wno-error -Wformat -Wformat-security -D_POSIX -DBUILD_LIBRARY -D_FORTIFY_SOURCE=2 -fstack-protector-strong
+// #cgo windows CFLAGS: -g -w -DMICROSTACK_NO_STDAFX -DWIN32 -DWIN64 -DNDEBUG -D_CONSOLE -DMICROSTACK_NO_STDAFX -DWINSOCK2 -DMICROSTACK_NOTLS -D_UNICODE -D_WINDOWS -D_WIN32_WINNT=0x0A00 -DBUILD_LIBRARY
+// #cgo windows LDFLAGS: -lDbgHelp -lIphlpapi -lSetupapi -lws2_32 -lPsapi -lCrypt32 -lWintrust -lVersion -lWtsapi32 -lGdiplus -lUserenv -lgdi32 -lucrtbase
+// #include "../../microlms/MicroLMS/main.c"
+// #include "../../microlms/core/utils.c"
+// #include "../../microlms/heci/HECIWin.c"
+// #include "../../microlms/heci/HECILinux.c"
+// #include "../../microlms/heci/LMEConnection.c"
+// #include "../../microlms/heci/PTHICommand.c"
+// #include "../../microlms/microstack/ILibAsyncServerSocket.c"
+// #include "../../microlms/microstack/ILibAsyncSocket.c"
+// #include "../../microlms/microstack/ILibLMS.c"
+// #include "../../microlms/microstack/ILibParsers.c"
+import "C"
+import "errors"
+
+// InitiateLMS runs the embedded MicroLMS and only returns once it has stopped
+func (amt Command) InitiateLMS() error {
+	C.main_micro_lms()
+	return errors.New("MicroLMS stopped")
+}
@@ -0,0 +1,21 @@
+//go:build !cgo || nocgo
+// +build !cgo nocgo
+
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package amt
+
+import (
+	"errors"
+	"rpc/pkg/utils"
+)
+
+// ErrExternalLMSRequired is returned when rpc is built without the embedded MicroLMS
+var ErrExternalLMSRequired = errors.New("rpc was built without MicroLMS, an external LMS must be running and listening on " + utils.LMSAddress + ":" + utils.LMSPort)
+
+// InitiateLMS fails immediately since MicroLMS is only available in cgo builds
+func (amt Command) InitiateLMS() error {
+	return ErrExternalLMSRequired
+}
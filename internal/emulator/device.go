@@ -0,0 +1,154 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package emulator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// Device is the state of an emulated AMT device as stored in a JSON fixture
+type Device struct {
+	ControlMode         int                `json:"controlMode"`
+	ProvisioningState   int                `json:"provisioningState"`
+	ProvisioningMode    uint32             `json:"provisioningMode"`
+	ProvisioningTLSMode uint32             `json:"provisioningTLSMode"`
+	ZeroTouchEnabled    bool               `json:"zeroTouchEnabled"`
+	UUID                string             `json:"uuid"`
+	BiosVersion         string             `json:"biosVersion"`
+	Versions            []Version          `json:"versions"`
+	DNSSuffix           string             `json:"dnsSuffix"`
+	HostFQDN            string             `json:"hostFQDN"`
+	EHBCEnabled         bool               `json:"ehbcEnabled"`
+	Features            Features           `json:"features"`
+	LastResetReason     LastResetReason    `json:"lastResetReason"`
+	PowerPolicy         string             `json:"powerPolicy"`
+	CertHashes          []CertHash         `json:"certHashes"`
+	Wired               LANSettings        `json:"wired"`
+	Wireless            LANSettings        `json:"wireless"`
+	DedicatedMAC        string             `json:"dedicatedMAC"`
+	LocalSystemAccount  LocalSystemAccount `json:"localSystemAccount"`
+}
+
+// Features are the states reported by GET_FEATURES_STATE
+type Features struct {
+	IDEROpen               bool `json:"iderOpen"`
+	SOLOpen                bool `json:"solOpen"`
+	SystemDefenseActivated bool `json:"systemDefenseActivated"`
+	WebUIEnabled           bool `json:"webUIEnabled"`
+}
+
+// LastResetReason is the cause of the last host reset, 0 being a remote reset through AMT
+type LastResetReason struct {
+	Reason                 uint32 `json:"reason"`
+	RemoteControlTimeStamp uint32 `json:"remoteControlTimeStamp"`
+}
+
+// Version is an entry of the firmware version table, e.g. "AMT", "Build Number" or "Sku"
+type Version struct {
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// CertHash is a trusted root certificate hash. Algorithm uses the PTHI codes, 2 being SHA256.
+type CertHash struct {
+	Name      string `json:"name"`
+	Algorithm uint8  `json:"algorithm"`
+	Hash      string `json:"hash"`
+	IsDefault bool   `json:"isDefault"`
+	IsActive  bool   `json:"isActive"`
+}
+
+// LANSettings are the settings of the wired or wireless interface
+type LANSettings struct {
+	Enabled     bool   `json:"enabled"`
+	IPAddress   string `json:"ipAddress"`
+	DHCPEnabled bool   `json:"dhcpEnabled"`
+	DHCPMode    uint8  `json:"dhcpMode"`
+	LinkUp      bool   `json:"linkUp"`
+	MACAddress  string `json:"macAddress"`
+}
+
+// LocalSystemAccount holds the credentials of the local system account
+type LocalSystemAccount struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Load reads a Device from the JSON fixture at path. A device that is activated but does not
+// state its provisioning state is taken to be post-provisioning.
+func Load(path string) (*Device, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	device := &Device{}
+	err = json.Unmarshal(data, device)
+	if err != nil {
+		return nil, errors.New("unable to parse emulator fixture: " + err.Error())
+	}
+	if device.ControlMode != 0 && device.ProvisioningState == 0 {
+		device.ProvisioningState = 2
+	}
+	err = device.validate()
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// validate checks the fields that are converted to their wire format
+func (device *Device) validate() error {
+	_, err := encodeUUID(device.UUID)
+	if err != nil {
+		return err
+	}
+	for _, hash := range device.CertHashes {
+		_, err = hex.DecodeString(hash.Hash)
+		if err != nil {
+			return errors.New("invalid hash for certificate " + hash.Name)
+		}
+	}
+	if device.DedicatedMAC != "" {
+		_, err = net.ParseMAC(device.DedicatedMAC)
+		if err != nil {
+			return err
+		}
+	}
+	for _, lan := range []LANSettings{device.Wired, device.Wireless} {
+		if lan.IPAddress != "" && net.ParseIP(lan.IPAddress).To4() == nil {
+			return errors.New("invalid ipv4 address " + lan.IPAddress)
+		}
+		if lan.MACAddress != "" {
+			_, err = net.ParseMAC(lan.MACAddress)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeUUID converts a UUID string into the byte order AMT reports it in,
+// where the first three groups are little endian
+func encodeUUID(uuid string) ([16]uint8, error) {
+	result := [16]uint8{}
+	if uuid == "" {
+		return result, nil
+	}
+	data, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil || len(data) != 16 {
+		return result, errors.New("invalid uuid " + uuid)
+	}
+	order := []int{3, 2, 1, 0, 5, 4, 7, 6, 8, 9, 10, 11, 12, 13, 14, 15}
+	for i, j := range order {
+		result[i] = data[j]
+	}
+	return result, nil
+}
@@ -0,0 +1,286 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"rpc/pkg/heci"
+	"rpc/pkg/pthi"
+	"sync"
+)
+
+// Emulator is a heci.Transport that answers PTHI requests from the state of a Device and applies
+// the state changes of unprovisioning and starting or stopping configuration. Commands it does not
+// emulate are answered with AMT_STATUS_UNSUPPORTED. ServeLMS answers the WS-Man side of activation.
+type Emulator struct {
+	Device   *Device
+	lock     sync.Mutex
+	response []byte
+}
+
+func New(device *Device) *Emulator {
+	return &Emulator{
+		Device: device,
+	}
+}
+
+// Install loads the fixture at path and makes every PTHI command in this process use the emulator
+func Install(path string) (*Emulator, error) {
+	device, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	emulator := New(device)
+	heci.NewTransport = func() heci.Transport {
+		return emulator
+	}
+	return emulator, nil
+}
+
+func (emulator *Emulator) Init() error {
+	return nil
+}
+func (emulator *Emulator) GetBufferSize() uint32 {
+	return heci.FakeBufferSize
+}
+func (emulator *Emulator) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
+	if len(buffer) < 12 {
+		return 0, errors.New("request header is truncated")
+	}
+	emulator.lock.Lock()
+	defer emulator.lock.Unlock()
+	emulator.response = emulator.handle(binary.LittleEndian.Uint32(buffer[4:8]), bytes.NewBuffer(buffer[12:]))
+	return uint32(len(buffer)), nil
+}
+func (emulator *Emulator) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
+	emulator.lock.Lock()
+	defer emulator.lock.Unlock()
+	if emulator.response == nil {
+		return 0, errors.New("no request pending")
+	}
+	read := copy(buffer, emulator.response)
+	emulator.response = nil
+	return uint32(read), nil
+}
+func (emulator *Emulator) Close() {}
+
+// handle answers a single request
+func (emulator *Emulator) handle(command uint32, payload *bytes.Buffer) []byte {
+	device := emulator.Device
+	switch command {
+	case pthi.GET_UUID_REQUEST:
+		uuid, _ := encodeUUID(device.UUID)
+		return response(command, pthi.AMT_STATUS_SUCCESS, uuid)
+	case pthi.GET_CONTROL_MODE_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, uint32(device.ControlMode))
+	case pthi.PROVISIONING_STATE_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, uint32(device.ProvisioningState))
+	case pthi.PROVISIONING_MODE_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, device.ProvisioningMode, boolean(false))
+	case pthi.GET_PROVISIONING_TLS_MODE_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, device.ProvisioningTLSMode)
+	case pthi.GET_ZERO_TOUCH_ENABLED_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, boolean(device.ZeroTouchEnabled))
+	case pthi.CODE_VERSIONS_REQUEST:
+		return emulator.codeVersions(command)
+	case pthi.ENUMERATE_HASH_HANDLES_REQUEST:
+		handles := []uint32{uint32(len(device.CertHashes))}
+		for i := range device.CertHashes {
+			handles = append(handles, uint32(i))
+		}
+		return response(command, pthi.AMT_STATUS_SUCCESS, handles)
+	case pthi.GET_CERTHASH_ENTRY_REQUEST:
+		return emulator.certHashEntry(command, payload)
+	case pthi.GET_PKI_FQDN_SUFFIX_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, ansiString(device.DNSSuffix))
+	case pthi.SET_DNS_SUFFIX_REQUEST:
+		if device.ProvisioningState != 0 {
+			return response(command, pthi.AMT_STATUS_INVALID_PROVISIONING_STATE)
+		}
+		suffix, ok := readANSIString(payload)
+		if !ok {
+			return response(command, pthi.AMT_STATUS_INVALID_MESSAGE_LENGTH)
+		}
+		device.DNSSuffix = suffix
+		return response(command, pthi.AMT_STATUS_SUCCESS)
+	case pthi.GET_FQDN_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, boolean(true), boolean(false), uint32(0), uint32(0), uint32(len(device.HostFQDN)), []byte(device.HostFQDN))
+	case pthi.SET_HOST_FQDN_REQUEST:
+		// the request always carries the whole FQDN array, whatever the length of the name
+		fqdn, ok := readANSIString(payload)
+		if !ok || payload.Len() != pthi.FQDN_MAX_SIZE-len(fqdn) {
+			return response(command, pthi.AMT_STATUS_INVALID_MESSAGE_LENGTH)
+		}
+		device.HostFQDN = fqdn
+		return response(command, pthi.AMT_STATUS_SUCCESS)
+	case pthi.GET_EHBC_STATE_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, boolean(device.EHBCEnabled))
+	case pthi.GET_FEATURES_STATE_REQUEST:
+		return emulator.featuresState(command, payload)
+	case pthi.GET_LAST_HOST_RESET_REASON_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, device.LastResetReason.Reason, device.LastResetReason.RemoteControlTimeStamp)
+	case pthi.GET_CURRENT_POWER_POLICY_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, ansiString(device.PowerPolicy))
+	case pthi.GET_RNG_SEED_STATUS_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, uint32(pthi.RNG_STATUS_EXIST))
+	case pthi.GET_REMOTE_ACCESS_CONNECTION_STATUS_REQUEST:
+		return response(command, pthi.AMT_STATUS_SUCCESS, uint32(0), uint32(0), uint32(0), ansiString(""))
+	case pthi.GET_LAN_INTERFACE_SETTINGS_REQUEST:
+		var index uint32
+		binary.Read(payload, binary.LittleEndian, &index)
+		if index == 1 {
+			return lanInterfaceSettings(command, device.Wireless)
+		}
+		return lanInterfaceSettings(command, device.Wired)
+	case pthi.GET_MAC_ADDRESSES_REQUEST:
+		// the wired interface is shared with the host, AMT only has a MAC of its own when the fixture gives one
+		dedicated, host := [6]uint8{}, [6]uint8{}
+		mac, _ := net.ParseMAC(device.DedicatedMAC)
+		copy(dedicated[:], mac)
+		mac, _ = net.ParseMAC(device.Wired.MACAddress)
+		copy(host[:], mac)
+		return response(command, pthi.AMT_STATUS_SUCCESS, dedicated, host)
+	case pthi.GET_LOCAL_SYSTEM_ACCOUNT_REQUEST:
+		account := pthi.LocalSystemAccount{}
+		copy(account.Username[:], device.LocalSystemAccount.Username)
+		copy(account.Password[:], device.LocalSystemAccount.Password)
+		return response(command, pthi.AMT_STATUS_SUCCESS, account)
+	case pthi.START_CONFIGURATION_REQUEST:
+		if device.ProvisioningState != 0 {
+			return response(command, pthi.AMT_STATUS_INVALID_PROVISIONING_STATE)
+		}
+		device.ProvisioningState = 1
+		return response(command, pthi.AMT_STATUS_SUCCESS)
+	case pthi.STOP_CONFIGURATION_REQUEST:
+		if device.ProvisioningState != 1 {
+			return response(command, pthi.AMT_STATUS_INVALID_PROVISIONING_STATE)
+		}
+		device.ProvisioningState = 0
+		return response(command, pthi.AMT_STATUS_SUCCESS)
+	case pthi.UNPROVISION_REQUEST:
+		if device.ProvisioningState != 2 {
+			return response(command, pthi.AMT_STATUS_INVALID_PROVISIONING_STATE)
+		}
+		if device.ControlMode != 1 {
+			return response(command, pthi.AMT_STATUS_INVALID_PT_MODE)
+		}
+		device.ControlMode = 0
+		device.ProvisioningState = 0
+		return response(command, pthi.AMT_STATUS_SUCCESS)
+	default:
+		return response(command, pthi.AMT_STATUS_UNSUPPORTED)
+	}
+}
+
+func (emulator *Emulator) codeVersions(command uint32) []byte {
+	bios := [pthi.BIOS_VERSION_LEN]uint8{}
+	copy(bios[:], emulator.Device.BiosVersion)
+	versions := []pthi.AMTVersionType{}
+	for _, v := range emulator.Device.Versions {
+		versions = append(versions, pthi.AMTVersionType{
+			Description: unicodeString(v.Description),
+			Version:     unicodeString(v.Version),
+		})
+	}
+	return response(command, pthi.AMT_STATUS_SUCCESS, bios, uint32(len(versions)), versions)
+}
+
+func (emulator *Emulator) certHashEntry(command uint32, payload *bytes.Buffer) []byte {
+	var handle uint32
+	binary.Read(payload, binary.LittleEndian, &handle)
+	if handle >= uint32(len(emulator.Device.CertHashes)) {
+		return response(command, pthi.AMT_STATUS_INVALID_HANDLE)
+	}
+	entry := emulator.Device.CertHashes[handle]
+	hash := [pthi.CERT_HASH_MAX_LENGTH]uint8{}
+	data, _ := hex.DecodeString(entry.Hash)
+	copy(hash[:], data)
+	return response(command, pthi.AMT_STATUS_SUCCESS, boolean(entry.IsDefault), boolean(entry.IsActive), hash, entry.Algorithm, ansiString(entry.Name))
+}
+
+func (emulator *Emulator) featuresState(command uint32, payload *bytes.Buffer) []byte {
+	features := emulator.Device.Features
+	var requestID uint32
+	binary.Read(payload, binary.LittleEndian, &requestID)
+	data := [3]uint32{}
+	switch requestID {
+	case pthi.REDIRECTION_SESSION:
+		data[0] = boolean(features.IDEROpen)
+		data[1] = boolean(features.SOLOpen)
+	case pthi.SYSTEM_DEFENSE:
+		data[0] = boolean(features.SystemDefenseActivated)
+	case pthi.WEB_UI:
+		data[0] = boolean(features.WebUIEnabled)
+	default:
+		return response(command, pthi.AMT_STATUS_INVALID_PARAMETER)
+	}
+	return response(command, pthi.AMT_STATUS_SUCCESS, requestID, data)
+}
+
+func lanInterfaceSettings(command uint32, lan LANSettings) []byte {
+	var address uint32
+	ip := net.ParseIP(lan.IPAddress).To4()
+	if ip != nil {
+		address = binary.BigEndian.Uint32(ip)
+	}
+	mac, _ := net.ParseMAC(lan.MACAddress)
+	macAddress := [6]uint8{}
+	copy(macAddress[:], mac)
+	var linkStatus uint8
+	if lan.LinkUp {
+		linkStatus = 1
+	}
+	return response(command, pthi.AMT_STATUS_SUCCESS, boolean(lan.Enabled), address, boolean(lan.DHCPEnabled), lan.DHCPMode, linkStatus, macAddress)
+}
+
+// response builds the answer to command with the given status followed by each payload value
+func response(command uint32, status pthi.AMTStatus, payload ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, value := range payload {
+		binary.Write(&body, binary.LittleEndian, value)
+	}
+	header := pthi.CreateRequestHeader(command | pthi.RESPONSE_FLAG)
+	header.Length = uint32(4 + body.Len())
+	var result bytes.Buffer
+	binary.Write(&result, binary.LittleEndian, header)
+	binary.Write(&result, binary.LittleEndian, uint32(status))
+	result.Write(body.Bytes())
+	return result.Bytes()
+}
+
+// ansiString encodes value as an AMT_ANSI_STRING
+func ansiString(value string) []byte {
+	result := make([]byte, 2, 2+len(value))
+	binary.LittleEndian.PutUint16(result, uint16(len(value)))
+	return append(result, value...)
+}
+
+// readANSIString reads an AMT_ANSI_STRING from a request payload
+func readANSIString(payload *bytes.Buffer) (string, bool) {
+	var length uint16
+	err := binary.Read(payload, binary.LittleEndian, &length)
+	if err != nil || int(length) > payload.Len() {
+		return "", false
+	}
+	return string(payload.Next(int(length))), true
+}
+
+func unicodeString(value string) pthi.AMTUnicodeString {
+	result := pthi.AMTUnicodeString{Length: uint16(len(value))}
+	copy(result.String[:], value)
+	return result
+}
+
+// boolean encodes value as an AMT_BOOLEAN
+func boolean(value bool) uint32 {
+	if value {
+		return 1
+	}
+	return 0
+}
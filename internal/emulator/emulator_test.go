@@ -0,0 +1,157 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package emulator
+
+import (
+	"errors"
+	"rpc/internal/amt"
+	"rpc/internal/local"
+	"rpc/internal/rpc"
+	"rpc/pkg/heci"
+	"rpc/pkg/pthi"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setup(t *testing.T) *Device {
+	device, err := Load("testdata/ccm.json")
+	assert.NoError(t, err)
+	emulator := New(device)
+	original := heci.NewTransport
+	heci.NewTransport = func() heci.Transport { return emulator }
+	t.Cleanup(func() { heci.NewTransport = original })
+	return device
+}
+
+func TestLoad(t *testing.T) {
+	device, err := Load("testdata/ccm.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, device.ControlMode)
+	assert.Equal(t, 2, device.ProvisioningState)
+}
+func TestLoadMissing(t *testing.T) {
+	_, err := Load("testdata/missing.json")
+	assert.Error(t, err)
+}
+func TestInstall(t *testing.T) {
+	original := heci.NewTransport
+	defer func() { heci.NewTransport = original }()
+	emulator, err := Install("testdata/ccm.json")
+	assert.NoError(t, err)
+	assert.Same(t, emulator, heci.NewTransport())
+}
+
+func TestAMTInfo(t *testing.T) {
+	setup(t)
+	command := amt.Command{}
+	uuid, err := command.GetUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, "4c4c4544-0034-4410-8051-b3c04f4e3332", uuid)
+	version, err := command.GetVersionDataFromME("AMT")
+	assert.NoError(t, err)
+	assert.Equal(t, "15.0.10", version)
+	mode, err := command.GetControlMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mode)
+	suffix, err := command.GetDNSSuffix()
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", suffix)
+	fqdn, err := command.GetFQDN()
+	assert.NoError(t, err)
+	assert.Equal(t, "host.example.com", fqdn.FQDN)
+	hashes, err := command.GetCertificateHashes()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(hashes))
+	assert.Equal(t, "Test Root", hashes[0].Name)
+	assert.Equal(t, "e7685634efacf69ace939a6b255b7b4fabef42935b50a265acb5cb6027e44e70", hashes[0].Hash)
+	lan, err := command.GetLANInterfaceSettings(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.10", lan.IPAddress.String())
+	assert.Equal(t, "00:11:22:33:44:55", lan.MACAddress.String())
+	assert.Equal(t, "up", lan.LinkStatus)
+	macs, err := command.GetMACAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, "00:11:22:33:44:55", macs.HostMAC.String())
+	assert.Equal(t, "00:00:00:00:00:00", macs.DedicatedMAC.String())
+	lsa, err := command.GetLocalSystemAccount()
+	assert.NoError(t, err)
+	assert.Equal(t, "$$OsAdmin", lsa.Username)
+	assert.Equal(t, "P@ssw0rd", lsa.Password)
+	ehbc, err := command.GetEHBCEnabled()
+	assert.NoError(t, err)
+	assert.True(t, ehbc)
+	provisioningMode, err := command.GetProvisioningMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, provisioningMode)
+	tlsMode, err := command.GetProvisioningTLSMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tlsMode)
+	zeroTouch, err := command.GetZeroTouchEnabled()
+	assert.NoError(t, err)
+	assert.False(t, zeroTouch)
+	features, err := command.GetFeaturesState()
+	assert.NoError(t, err)
+	assert.True(t, features.WebUIEnabled)
+	assert.False(t, features.SOLOpen)
+	reason, err := command.GetLastHostResetReason()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reason)
+	policy, err := command.GetCurrentPowerPolicy()
+	assert.NoError(t, err)
+	assert.Equal(t, "Desktop: ON in S0", policy)
+}
+
+func TestDeactivate(t *testing.T) {
+	device := setup(t)
+	err := local.NewLocalConfiguration(rpc.Flags{}, amt.Command{}).Run("deactivate")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, device.ControlMode)
+	assert.Equal(t, 0, device.ProvisioningState)
+}
+func TestUnprovisionACM(t *testing.T) {
+	device := setup(t)
+	device.ControlMode = 2
+	command := pthi.NewPTHICommand()
+	err := command.Unprovision()
+	assert.True(t, errors.Is(err, pthi.AMT_STATUS_INVALID_PT_MODE))
+	assert.Equal(t, 2, device.ControlMode)
+}
+func TestConfiguration(t *testing.T) {
+	device := setup(t)
+	device.ControlMode = 0
+	device.ProvisioningState = 0
+	command := pthi.NewPTHICommand()
+	assert.NoError(t, command.SetDNSSuffix("other.com"))
+	assert.Equal(t, "other.com", device.DNSSuffix)
+	assert.NoError(t, command.StartConfiguration())
+	assert.Equal(t, 1, device.ProvisioningState)
+	assert.True(t, errors.Is(command.StartConfiguration(), pthi.AMT_STATUS_INVALID_PROVISIONING_STATE))
+	assert.NoError(t, command.StopConfiguration())
+	assert.Equal(t, 0, device.ProvisioningState)
+}
+func TestDedicatedMAC(t *testing.T) {
+	device := setup(t)
+	device.DedicatedMAC = "00:aa:bb:cc:dd:ee"
+	macs, err := amt.Command{}.GetMACAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, "00:aa:bb:cc:dd:ee", macs.DedicatedMAC.String())
+	assert.Equal(t, "00:11:22:33:44:55", macs.HostMAC.String())
+}
+func TestSetHostFQDN(t *testing.T) {
+	device := setup(t)
+	command := amt.Command{}
+	assert.NoError(t, command.SetHostFQDN("other.example.com"))
+	assert.Equal(t, "other.example.com", device.HostFQDN)
+	fqdn, err := command.GetFQDN()
+	assert.NoError(t, err)
+	assert.Equal(t, "other.example.com", fqdn.FQDN)
+}
+func TestUnsupported(t *testing.T) {
+	setup(t)
+	command := pthi.NewPTHICommand()
+	err := command.GenerateRNGSeed()
+	assert.True(t, errors.Is(err, pthi.AMT_STATUS_UNSUPPORTED))
+}
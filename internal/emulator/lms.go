@@ -0,0 +1,206 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package emulator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"rpc/pkg/pthi"
+	"strconv"
+	"strings"
+)
+
+const (
+	wsmanGet   = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Get"
+	wsmanFault = "http://schemas.xmlsoap.org/ws/2004/08/addressing/fault"
+
+	amtSchema                 = "http://intel.com/wbem/wscim/1/amt-schema/1/"
+	ipsSchema                 = "http://intel.com/wbem/wscim/1/ips-schema/1/"
+	generalSettingsURI        = amtSchema + "AMT_GeneralSettings"
+	setupAndConfigurationURI  = amtSchema + "AMT_SetupAndConfigurationService"
+	hostBasedSetupURI         = ipsSchema + "IPS_HostBasedSetupService"
+	actionSetup               = hostBasedSetupURI + "/Setup"
+	actionAdminSetup          = hostBasedSetupURI + "/AdminSetup"
+	actionAddNextCertInChain  = hostBasedSetupURI + "/AddNextCertInChain"
+	actionUnprovision         = setupAndConfigurationURI + "/Unprovision"
+	hostBasedSetupServiceName = "Intel(r) AMT Host Based Setup Service"
+	setupAndConfigurationName = "Intel(r) AMT Setup and Configuration Service"
+	generalSettingsName       = "Intel(r) AMT: General Settings"
+	configurationNonce        = "emulated configuration nonce"
+)
+
+// envelope is the part of a WS-Man request the emulator needs to answer it
+type envelope struct {
+	Action      string `xml:"Header>Action"`
+	ResourceURI string `xml:"Header>ResourceURI"`
+	MessageID   string `xml:"Header>MessageID"`
+}
+
+// property is a single property of a CIM instance, kept in the order AMT reports it
+type property struct {
+	name  string
+	value string
+}
+
+// lms answers the WS-Man requests a provisioning server sends through LMS to activate or deactivate
+// AMT. Requests without digest credentials are challenged; the credentials themselves are not checked.
+// Resources and methods it does not emulate are answered with an ActionNotSupported fault.
+type lms struct {
+	emulator *Emulator
+}
+
+// ServeLMS answers WS-Man requests on address in place of LMS until listening fails
+func (emulator *Emulator) ServeLMS(address string) error {
+	listener, err := net.Listen("tcp4", address)
+	if err != nil {
+		return err
+	}
+	return http.Serve(listener, &lms{emulator: emulator})
+}
+
+func (lms *lms) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	emulator := lms.emulator
+	emulator.lock.Lock()
+	defer emulator.lock.Unlock()
+	if !strings.HasPrefix(request.Header.Get("Authorization"), "Digest ") {
+		writer.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", stale="false", qop="auth"`, emulator.digestRealm(), hex.EncodeToString([]byte(configurationNonce))))
+		http.Error(writer, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := envelope{}
+	err = xml.Unmarshal(body, &message)
+	if err != nil {
+		http.Error(writer, "request is not a WS-Man envelope", http.StatusBadRequest)
+		return
+	}
+	status := http.StatusOK
+	action := message.Action + "Response"
+	result, ok := emulator.wsman(message)
+	if !ok {
+		status = http.StatusBadRequest
+		action = wsmanFault
+		result = `<a:Fault><a:Code><a:Value>a:Sender</a:Value><a:Subcode><a:Value>b:ActionNotSupported</a:Value></a:Subcode></a:Code>` +
+			`<a:Reason><a:Text xml:lang="en-US">The action is not supported by the service.</a:Text></a:Reason></a:Fault>`
+	}
+	writer.Header().Set("Content-Type", "application/soap+xml; charset=UTF-8")
+	writer.WriteHeader(status)
+	writer.Write(responseEnvelope(message, action, result))
+}
+
+// wsman answers a single WS-Man request, returning false for those the emulator does not support
+func (emulator *Emulator) wsman(message envelope) (string, bool) {
+	device := emulator.Device
+	switch {
+	case message.Action == wsmanGet && message.ResourceURI == generalSettingsURI:
+		hostname := strings.SplitN(device.HostFQDN, ".", 2)[0]
+		return instance(generalSettingsURI, "AMT_GeneralSettings",
+			property{"DigestRealm", emulator.digestRealm()},
+			property{"DomainName", device.DNSSuffix},
+			property{"ElementName", generalSettingsName},
+			property{"HostName", hostname},
+			property{"InstanceID", generalSettingsName},
+			property{"NetworkInterfaceEnabled", "true"}), true
+	case message.Action == wsmanGet && message.ResourceURI == hostBasedSetupURI:
+		return instance(hostBasedSetupURI, "IPS_HostBasedSetupService",
+			property{"AllowedControlModes", "1"},
+			property{"AllowedControlModes", "2"},
+			property{"CertChainStatus", "0"},
+			property{"ConfigurationNonce", base64.StdEncoding.EncodeToString([]byte(configurationNonce))},
+			property{"CreationClassName", "IPS_HostBasedSetupService"},
+			property{"CurrentControlMode", strconv.Itoa(device.ControlMode)},
+			property{"ElementName", hostBasedSetupServiceName},
+			property{"Name", hostBasedSetupServiceName},
+			property{"SystemCreationClassName", "CIM_ComputerSystem"},
+			property{"SystemName", "Intel(r) AMT"}), true
+	case message.Action == wsmanGet && message.ResourceURI == setupAndConfigurationURI:
+		return instance(setupAndConfigurationURI, "AMT_SetupAndConfigurationService",
+			property{"CreationClassName", "AMT_SetupAndConfigurationService"},
+			property{"ElementName", setupAndConfigurationName},
+			property{"Name", setupAndConfigurationName},
+			property{"ProvisioningMode", strconv.FormatUint(uint64(device.ProvisioningMode), 10)},
+			property{"ProvisioningState", strconv.Itoa(device.ProvisioningState)},
+			property{"SystemCreationClassName", "CIM_ComputerSystem"},
+			property{"SystemName", "Intel(r) AMT"},
+			property{"ZeroTouchConfigurationEnabled", strconv.FormatBool(device.ZeroTouchEnabled)}), true
+	case message.Action == actionSetup:
+		return output(hostBasedSetupURI, "Setup", emulator.activate(1)), true
+	case message.Action == actionAdminSetup:
+		return output(hostBasedSetupURI, "AdminSetup", emulator.activate(2)), true
+	case message.Action == actionAddNextCertInChain:
+		return output(hostBasedSetupURI, "AddNextCertInChain", pthi.AMT_STATUS_SUCCESS), true
+	case message.Action == actionUnprovision:
+		if device.ProvisioningState != 2 {
+			return output(setupAndConfigurationURI, "Unprovision", pthi.AMT_STATUS_INVALID_PROVISIONING_STATE), true
+		}
+		device.ControlMode = 0
+		device.ProvisioningState = 0
+		return output(setupAndConfigurationURI, "Unprovision", pthi.AMT_STATUS_SUCCESS), true
+	default:
+		return "", false
+	}
+}
+
+// activate moves a device in pre-provisioning state into the given control mode
+func (emulator *Emulator) activate(controlMode int) pthi.AMTStatus {
+	device := emulator.Device
+	if device.ProvisioningState != 0 {
+		return pthi.AMT_STATUS_INVALID_PROVISIONING_STATE
+	}
+	device.ControlMode = controlMode
+	device.ProvisioningState = 2
+	return pthi.AMT_STATUS_SUCCESS
+}
+
+// digestRealm derives the realm AMT reports for digest authentication from the device UUID
+func (emulator *Emulator) digestRealm() string {
+	uuid := strings.ToUpper(strings.Replace(emulator.Device.UUID, "-", "", -1))
+	return "Digest:" + uuid
+}
+
+// instance renders a CIM instance of class with its properties
+func instance(resourceURI string, class string, properties ...property) string {
+	var result bytes.Buffer
+	fmt.Fprintf(&result, `<h:%s xmlns:h="%s">`, class, resourceURI)
+	for _, p := range properties {
+		fmt.Fprintf(&result, "<h:%s>", p.name)
+		xml.EscapeText(&result, []byte(p.value))
+		fmt.Fprintf(&result, "</h:%s>", p.name)
+	}
+	fmt.Fprintf(&result, "</h:%s>", class)
+	return result.String()
+}
+
+// output renders the result of invoking method
+func output(resourceURI string, method string, status pthi.AMTStatus) string {
+	return instance(resourceURI, method+"_OUTPUT", property{"ReturnValue", strconv.FormatUint(uint64(status), 10)})
+}
+
+// responseEnvelope wraps body in a SOAP envelope answering message with action
+func responseEnvelope(message envelope, action string, body string) []byte {
+	var result bytes.Buffer
+	result.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	result.WriteString(`<a:Envelope xmlns:a="http://www.w3.org/2003/05/soap-envelope" xmlns:b="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:c="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd">`)
+	result.WriteString(`<a:Header><b:To>http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</b:To><b:RelatesTo>`)
+	xml.EscapeText(&result, []byte(message.MessageID))
+	result.WriteString(`</b:RelatesTo><b:Action a:mustUnderstand="true">`)
+	xml.EscapeText(&result, []byte(action))
+	result.WriteString(`</b:Action><c:ResourceURI>`)
+	xml.EscapeText(&result, []byte(message.ResourceURI))
+	result.WriteString(`</c:ResourceURI></a:Header><a:Body>`)
+	result.WriteString(body)
+	result.WriteString(`</a:Body></a:Envelope>`)
+	return result.Bytes()
+}
@@ -0,0 +1,92 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package emulator
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wsman posts a WS-Man request for action on resourceURI to the emulated LMS
+func wsman(t *testing.T, server *httptest.Server, authorized bool, action string, resourceURI string) (int, string) {
+	body := `<?xml version="1.0" encoding="utf-8"?><Envelope xmlns="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd">` +
+		`<Header><a:Action>` + action + `</a:Action><a:To>/wsman</a:To><w:ResourceURI>` + resourceURI + `</w:ResourceURI><a:MessageID>1</a:MessageID></Header><Body></Body></Envelope>`
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/wsman", strings.NewReader(body))
+	assert.NoError(t, err)
+	if authorized {
+		request.Header.Set("Authorization", `Digest username="admin", realm="Digest:4C4C4544003444108051B3C04F4E3332"`)
+	}
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	return response.StatusCode, string(data)
+}
+
+func setupLMS(t *testing.T) (*Device, *httptest.Server) {
+	device, err := Load("testdata/ccm.json")
+	assert.NoError(t, err)
+	server := httptest.NewServer(&lms{emulator: New(device)})
+	t.Cleanup(server.Close)
+	return device, server
+}
+
+func TestLMSChallenge(t *testing.T) {
+	_, server := setupLMS(t)
+	response, err := http.Post(server.URL+"/wsman", "application/soap+xml", strings.NewReader(""))
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	assert.Contains(t, response.Header.Get("WWW-Authenticate"), `Digest realm="Digest:4C4C4544003444108051B3C04F4E3332"`)
+}
+func TestLMSGetHostBasedSetupService(t *testing.T) {
+	_, server := setupLMS(t)
+	status, body := wsman(t, server, true, wsmanGet, hostBasedSetupURI)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "<h:CurrentControlMode>1</h:CurrentControlMode>")
+	assert.Contains(t, body, "<b:RelatesTo>1</b:RelatesTo>")
+	assert.Contains(t, body, wsmanGet+"Response")
+}
+func TestLMSGetGeneralSettings(t *testing.T) {
+	_, server := setupLMS(t)
+	status, body := wsman(t, server, true, wsmanGet, generalSettingsURI)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "<h:HostName>host</h:HostName>")
+	assert.Contains(t, body, "<h:DomainName>example.com</h:DomainName>")
+}
+func TestLMSSetup(t *testing.T) {
+	device, server := setupLMS(t)
+	device.ControlMode = 0
+	device.ProvisioningState = 0
+	status, body := wsman(t, server, true, actionSetup, hostBasedSetupURI)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "<h:ReturnValue>0</h:ReturnValue>")
+	assert.Equal(t, 1, device.ControlMode)
+	assert.Equal(t, 2, device.ProvisioningState)
+}
+func TestLMSSetupActivated(t *testing.T) {
+	device, server := setupLMS(t)
+	_, body := wsman(t, server, true, actionAdminSetup, hostBasedSetupURI)
+	assert.NotContains(t, body, "<h:ReturnValue>0</h:ReturnValue>")
+	assert.Equal(t, 1, device.ControlMode)
+}
+func TestLMSUnprovision(t *testing.T) {
+	device, server := setupLMS(t)
+	status, _ := wsman(t, server, true, actionUnprovision, setupAndConfigurationURI)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, device.ControlMode)
+	assert.Equal(t, 0, device.ProvisioningState)
+}
+func TestLMSUnsupported(t *testing.T) {
+	_, server := setupLMS(t)
+	status, body := wsman(t, server, true, wsmanGet, "http://intel.com/wbem/wscim/1/amt-schema/1/AMT_EthernetPortSettings")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "ActionNotSupported")
+}
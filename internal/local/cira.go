@@ -0,0 +1,63 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ciraPollInterval is how often the remote access connection status is read while waiting
+var ciraPollInterval = time.Second
+
+// CIRA opens or closes a user initiated CIRA connection and waits for the MPS connection to follow
+func (local LocalConfiguration) CIRA() error {
+	switch local.flags.SubCommand {
+	case "connect":
+		err := local.amt.OpenUserInitiatedConnection()
+		if err != nil {
+			return fmt.Errorf("unable to open user initiated connection: %w", err)
+		}
+		return local.waitForRemoteAccessStatus("connected")
+	case "disconnect":
+		err := local.amt.CloseUserInitiatedConnection()
+		if err != nil {
+			return fmt.Errorf("unable to close user initiated connection: %w", err)
+		}
+		return local.waitForRemoteAccessStatus("not connected")
+	default:
+		return errors.New("unknown cira command: " + local.flags.SubCommand)
+	}
+}
+
+// waitForRemoteAccessStatus polls the remote access connection status, logging every transition,
+// until it reaches the expected status or the timeout given on the command line expires
+func (local LocalConfiguration) waitForRemoteAccessStatus(expected string) error {
+	timeout := time.After(local.flags.Timeout)
+	ticker := time.NewTicker(ciraPollInterval)
+	defer ticker.Stop()
+	previous := ""
+	for {
+		result, err := local.amt.GetRemoteAccessConnectionStatus()
+		if err != nil {
+			return fmt.Errorf("unable to read remote access connection status: %w", err)
+		}
+		if result.RemoteStatus != previous {
+			log.Info("CIRA Status: " + result.RemoteStatus + " (" + result.MPSHostname + ")")
+			previous = result.RemoteStatus
+		}
+		if result.RemoteStatus == expected {
+			return nil
+		}
+		select {
+		case <-timeout:
+			return errors.New("timed out waiting for CIRA status '" + expected + "', last status was '" + previous + "'")
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"rpc/internal/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupCIRA(subCommand string, statuses []string, err error) LocalConfiguration {
+	ciraPollInterval = time.Millisecond
+	remoteStatuses = statuses
+	openConnectionErr = err
+	return NewLocalConfiguration(rpc.Flags{SubCommand: subCommand, Timeout: 50 * time.Millisecond}, MockAMT{})
+}
+
+func TestCIRAConnect(t *testing.T) {
+	local := setupCIRA("connect", []string{"not connected", "connecting", "connecting", "connected"}, nil)
+	err := local.Run("cira")
+	assert.NoError(t, err)
+}
+func TestCIRAConnectTimeout(t *testing.T) {
+	local := setupCIRA("connect", []string{"not connected", "connecting"}, nil)
+	err := local.Run("cira")
+	assert.EqualError(t, err, "timed out waiting for CIRA status 'connected', last status was 'connecting'")
+}
+func TestCIRAConnectFails(t *testing.T) {
+	local := setupCIRA("connect", []string{"not connected"}, errors.New("amt returned status 1"))
+	err := local.Run("cira")
+	assert.EqualError(t, err, "unable to open user initiated connection: amt returned status 1")
+}
+func TestCIRADisconnect(t *testing.T) {
+	local := setupCIRA("disconnect", []string{"connected", "not connected"}, nil)
+	err := local.Run("cira")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"rpc/internal/amt"
+	"rpc/internal/rpc"
+	"rpc/pkg/utils"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LocalConfiguration runs commands directly against AMT over HECI without a server
+type LocalConfiguration struct {
+	flags rpc.Flags
+	amt   amt.AMT
+}
+
+func NewLocalConfiguration(flags rpc.Flags, amt amt.AMT) LocalConfiguration {
+	return LocalConfiguration{
+		flags: flags,
+		amt:   amt,
+	}
+}
+
+// Run executes the local variant of the given command
+func (local LocalConfiguration) Run(command string) error {
+	switch command {
+	case "deactivate":
+		return local.Deactivate()
+	case "configure":
+		return local.Configure()
+	case "maintenance":
+		return local.Maintenance()
+	case "cira":
+		return local.CIRA()
+	case "provisioning":
+		return local.Provisioning()
+	default:
+		return errors.New(command + " is not supported locally")
+	}
+}
+
+// Deactivate unprovisions a device activated in client control mode
+func (local LocalConfiguration) Deactivate() error {
+	controlMode, err := local.amt.GetControlMode()
+	if err != nil {
+		return err
+	}
+	if controlMode != 1 {
+		return errors.New("local deactivation is only supported in client control mode, device is in " + utils.InterpretControlMode(controlMode))
+	}
+	err = local.amt.Unprovision()
+	if err != nil {
+		return fmt.Errorf("unable to deactivate: %w", err)
+	}
+	log.Info("Status: Device deactivated")
+	return nil
+}
+
+// Configure applies the setting named by the configure sub command
+func (local LocalConfiguration) Configure() error {
+	switch local.flags.SubCommand {
+	case "dnssuffix":
+		return local.ConfigureDNSSuffix()
+	case "enterprise-access":
+		return local.ConfigureEnterpriseAccess()
+	case "rngseed":
+		return local.ConfigureRNGSeed()
+	default:
+		return errors.New("unknown configure command: " + local.flags.SubCommand)
+	}
+}
+
+// ConfigureDNSSuffix writes the PKI DNS suffix to AMT and reads it back to confirm it was stored.
+// The suffix defaults to the one of the OS interface bound to AMT.
+func (local LocalConfiguration) ConfigureDNSSuffix() error {
+	state, err := local.amt.GetProvisioningState()
+	if err != nil {
+		return err
+	}
+	if state != 0 {
+		return errors.New("dns suffix can only be set in pre-provisioning state, device is " + utils.InterpretProvisioningState(state))
+	}
+	suffix := local.flags.DNS
+	if suffix == "" {
+		suffix, _ = local.amt.GetOSDNSSuffix()
+		if suffix == "" {
+			return errors.New("unable to determine the dns suffix from the OS, use -d to specify one")
+		}
+		log.Info("Using DNS suffix from the OS: " + suffix)
+	}
+	err = local.amt.SetDNSSuffix(suffix)
+	if err != nil {
+		return fmt.Errorf("unable to set dns suffix: %w", err)
+	}
+	stored, err := local.amt.GetDNSSuffix()
+	if err != nil {
+		return fmt.Errorf("unable to read back dns suffix: %w", err)
+	}
+	if stored != suffix {
+		return errors.New("dns suffix read back as '" + stored + "', expected '" + suffix + "'")
+	}
+	log.Info("Status: DNS suffix set to " + stored)
+	return nil
+}
+
+// ConfigureEnterpriseAccess tells AMT whether the host has access to the enterprise network.
+// The host address defaults to the one of the OS interface bound to AMT.
+func (local LocalConfiguration) ConfigureEnterpriseAccess() error {
+	hostIP := net.ParseIP(local.flags.HostIP)
+	if hostIP == nil {
+		hostIP, _ = local.amt.GetOSIPAddress()
+		if hostIP == nil {
+			return errors.New("unable to determine the host ip address from the OS, use -ip to specify one")
+		}
+		log.Info("Using host IP address from the OS: " + hostIP.String())
+	}
+	err := local.amt.SetEnterpriseAccess(hostIP, local.flags.EnterpriseAccess)
+	if err != nil {
+		return fmt.Errorf("unable to set enterprise access: %w", err)
+	}
+	log.Info("Status: Enterprise access set to " + strconv.FormatBool(local.flags.EnterpriseAccess) + " for " + hostIP.String())
+	return nil
+}
+
+// Maintenance runs the maintenance task that was requested on the command line
+func (local LocalConfiguration) Maintenance() error {
+	switch local.flags.SubCommand {
+	case "syncfqdn":
+		return local.SyncHostFQDN()
+	default:
+		return errors.New("unknown maintenance task: " + local.flags.SubCommand)
+	}
+}
+
+// SyncHostFQDN writes the FQDN of the OS to AMT and reads it back to confirm it was stored
+func (local LocalConfiguration) SyncHostFQDN() error {
+	dnsSuffix, err := amt.DNSSuffix(local.amt, local.flags.DNS)
+	if dnsSuffix == "" {
+		if err != nil {
+			return err
+		}
+		return errors.New("unable to determine the dns suffix, use -d to specify one")
+	}
+	hostname, err := amt.Hostname(local.flags.Hostname)
+	if err != nil {
+		return err
+	}
+	fqdn := amt.JoinFQDN(hostname, dnsSuffix)
+	err = local.amt.SetHostFQDN(fqdn)
+	if err != nil {
+		return fmt.Errorf("unable to set host fqdn: %w", err)
+	}
+	stored, err := local.amt.GetFQDN()
+	if err != nil {
+		return fmt.Errorf("unable to read back host fqdn: %w", err)
+	}
+	if !strings.EqualFold(stored.FQDN, fqdn) {
+		return errors.New("host fqdn read back as '" + stored.FQDN + "', expected '" + fqdn + "'")
+	}
+	if !stored.Shared {
+		log.Warn("AMT does not share the host FQDN, it will keep using its own")
+	}
+	log.Info("Status: Host FQDN set to " + stored.FQDN)
+	return nil
+}
@@ -0,0 +1,282 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"net"
+	"rpc/internal/amt"
+	"rpc/internal/rpc"
+	"rpc/pkg/pthi"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock the AMT Hardware
+type MockAMT struct{}
+
+var controlMode int = 1
+var unprovisionErr error
+var unprovisionCalled bool
+var mebxDNSSuffix string
+var osDNSSuffix string
+var setDNSSuffixErr error
+var hostFQDN amt.FQDN
+var setHostFQDNErr error
+var remoteStatuses []string
+var openConnectionErr error
+var provisioningState int
+var configurationErr error
+var otp string
+var osIPAddress net.IP
+var rngStatuses []int
+var generateRNGSeedErr error
+var generateRNGSeedCalled bool
+var enterpriseAccessIP net.IP
+var enterpriseAccess bool
+var enterpriseAccessErr error
+
+func (c MockAMT) Initialize() (bool, error) {
+	return true, nil
+}
+func (c MockAMT) GetVersionDataFromME(key string) (string, error) { return "Version", nil }
+func (c MockAMT) GetUUID() (string, error)                        { return "123-456-789", nil }
+func (c MockAMT) GetUUIDV2() (string, error)                      { return "", nil }
+func (c MockAMT) GetControlMode() (int, error)                    { return controlMode, nil }
+func (c MockAMT) GetControlModeV2() (int, error)                  { return controlMode, nil }
+func (c MockAMT) GetProvisioningState() (int, error)              { return provisioningState, nil }
+func (c MockAMT) GetProvisioningMode() (int, error)               { return 0, nil }
+func (c MockAMT) GetProvisioningTLSMode() (int, error)            { return 0, nil }
+func (c MockAMT) GetZeroTouchEnabled() (bool, error)              { return false, nil }
+func (c MockAMT) GetEHBCEnabled() (bool, error)                   { return true, nil }
+func (c MockAMT) GetFeaturesState() (amt.FeaturesState, error)    { return amt.FeaturesState{}, nil }
+func (c MockAMT) GetLastHostResetReason() (int, error)            { return 0, nil }
+func (c MockAMT) GetCurrentPowerPolicy() (string, error)          { return "", nil }
+func (c MockAMT) GetOSDNSSuffix() (string, error)                 { return osDNSSuffix, nil }
+func (c MockAMT) GetOSIPAddress() (net.IP, error)                 { return osIPAddress, nil }
+func (c MockAMT) GetMACAddresses() (amt.MACAddresses, error)      { return amt.MACAddresses{}, nil }
+func (c MockAMT) GetDNSSuffix() (string, error)                   { return mebxDNSSuffix, nil }
+func (c MockAMT) SetDNSSuffix(suffix string) error {
+	if setDNSSuffixErr == nil {
+		mebxDNSSuffix = suffix
+	}
+	return setDNSSuffixErr
+}
+func (c MockAMT) GetDNSSuffixList() ([]string, error) { return []string{}, nil }
+func (c MockAMT) GetFQDN() (amt.FQDN, error)          { return hostFQDN, nil }
+func (c MockAMT) SetHostFQDN(fqdn string) error {
+	if setHostFQDNErr == nil {
+		hostFQDN.FQDN = fqdn
+	}
+	return setHostFQDNErr
+}
+func (c MockAMT) GetCertificateHashes() ([]amt.CertHashEntry, error) {
+	return []amt.CertHashEntry{}, nil
+}
+func (c MockAMT) GetRemoteAccessConnectionStatus() (amt.RemoteAccessStatus, error) {
+	status := remoteStatuses[0]
+	if len(remoteStatuses) > 1 {
+		remoteStatuses = remoteStatuses[1:]
+	}
+	return amt.RemoteAccessStatus{RemoteStatus: status, MPSHostname: "mps.example.com"}, nil
+}
+func (c MockAMT) OpenUserInitiatedConnection() error  { return openConnectionErr }
+func (c MockAMT) CloseUserInitiatedConnection() error { return nil }
+func (c MockAMT) GetLANInterfaceSettings(useWireless bool) (amt.InterfaceSettings, error) {
+	return amt.InterfaceSettings{}, nil
+}
+func (c MockAMT) GetRNGSeedStatus() (int, error) {
+	status := rngStatuses[0]
+	if len(rngStatuses) > 1 {
+		rngStatuses = rngStatuses[1:]
+	}
+	return status, nil
+}
+func (c MockAMT) GenerateRNGSeed() error {
+	generateRNGSeedCalled = true
+	return generateRNGSeedErr
+}
+func (c MockAMT) SetEnterpriseAccess(hostIP net.IP, access bool) error {
+	if enterpriseAccessErr == nil {
+		enterpriseAccessIP = hostIP
+		enterpriseAccess = access
+	}
+	return enterpriseAccessErr
+}
+func (c MockAMT) GetLocalSystemAccount() (amt.LocalSystemAccount, error) {
+	return amt.LocalSystemAccount{Username: "Username", Password: "Password"}, nil
+}
+func (c MockAMT) Unprovision() error {
+	unprovisionCalled = true
+	return unprovisionErr
+}
+func (c MockAMT) StartConfiguration() error {
+	if configurationErr == nil {
+		provisioningState = 1
+	}
+	return configurationErr
+}
+func (c MockAMT) StopConfiguration() error {
+	if configurationErr == nil {
+		provisioningState = 0
+	}
+	return configurationErr
+}
+func (c MockAMT) SetProvisioningServerOTP(value string) error {
+	otp = value
+	return configurationErr
+}
+func (c MockAMT) InitiateLMS() error { return nil }
+
+func setup(mode int, err error) LocalConfiguration {
+	controlMode = mode
+	unprovisionErr = err
+	unprovisionCalled = false
+	return NewLocalConfiguration(rpc.Flags{}, MockAMT{})
+}
+
+func setupDNSSuffix(state int, flagSuffix string, osSuffix string, err error) LocalConfiguration {
+	provisioningState = state
+	mebxDNSSuffix = ""
+	osDNSSuffix = osSuffix
+	setDNSSuffixErr = err
+	return NewLocalConfiguration(rpc.Flags{SubCommand: "dnssuffix", DNS: flagSuffix}, MockAMT{})
+}
+
+func TestDeactivateCCM(t *testing.T) {
+	local := setup(1, nil)
+	err := local.Run("deactivate")
+	assert.NoError(t, err)
+	assert.True(t, unprovisionCalled)
+}
+func TestDeactivateACM(t *testing.T) {
+	local := setup(2, nil)
+	err := local.Run("deactivate")
+	assert.Error(t, err)
+	assert.False(t, unprovisionCalled)
+}
+func TestDeactivatePreProvisioning(t *testing.T) {
+	local := setup(0, nil)
+	err := local.Run("deactivate")
+	assert.Error(t, err)
+	assert.False(t, unprovisionCalled)
+}
+func TestDeactivateReportsStatus(t *testing.T) {
+	local := setup(1, errors.New("amt returned status 1"))
+	err := local.Run("deactivate")
+	assert.EqualError(t, err, "unable to deactivate: amt returned status 1")
+}
+func TestDeactivateWrapsAMTStatus(t *testing.T) {
+	local := setup(1, pthi.AMT_STATUS_BLOCKING_COMPONENT)
+	err := local.Run("deactivate")
+	assert.True(t, errors.Is(err, pthi.AMT_STATUS_BLOCKING_COMPONENT))
+}
+func TestRunUnsupported(t *testing.T) {
+	local := setup(1, nil)
+	err := local.Run("activate")
+	assert.Error(t, err)
+}
+
+func TestConfigureDNSSuffix(t *testing.T) {
+	local := setupDNSSuffix(0, "example.com", "os.example.com", nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", mebxDNSSuffix)
+}
+func TestConfigureDNSSuffixFromOS(t *testing.T) {
+	local := setupDNSSuffix(0, "", "os.example.com", nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.Equal(t, "os.example.com", mebxDNSSuffix)
+}
+func TestConfigureDNSSuffixNoOSSuffix(t *testing.T) {
+	local := setupDNSSuffix(0, "", "", nil)
+	err := local.Run("configure")
+	assert.Error(t, err)
+}
+func TestConfigureDNSSuffixInProvisioning(t *testing.T) {
+	local := setupDNSSuffix(1, "example.com", "", nil)
+	err := local.Run("configure")
+	assert.EqualError(t, err, "dns suffix can only be set in pre-provisioning state, device is in-provisioning")
+	assert.Equal(t, "", mebxDNSSuffix)
+}
+func TestConfigureDNSSuffixActivated(t *testing.T) {
+	local := setupDNSSuffix(2, "example.com", "", nil)
+	err := local.Run("configure")
+	assert.Error(t, err)
+	assert.Equal(t, "", mebxDNSSuffix)
+}
+func TestConfigureDNSSuffixFails(t *testing.T) {
+	local := setupDNSSuffix(0, "example.com", "", errors.New("amt returned status 1"))
+	err := local.Run("configure")
+	assert.EqualError(t, err, "unable to set dns suffix: amt returned status 1")
+}
+
+func setupSyncFQDN(mebxSuffix string, err error) LocalConfiguration {
+	mebxDNSSuffix = mebxSuffix
+	osDNSSuffix = ""
+	hostFQDN = amt.FQDN{Shared: true}
+	setHostFQDNErr = err
+	return NewLocalConfiguration(rpc.Flags{SubCommand: "syncfqdn", Hostname: "host"}, MockAMT{})
+}
+
+func TestSyncHostFQDN(t *testing.T) {
+	local := setupSyncFQDN("example.com", nil)
+	err := local.Run("maintenance")
+	assert.NoError(t, err)
+	assert.Equal(t, "host.example.com", hostFQDN.FQDN)
+}
+func TestSyncHostFQDNOverride(t *testing.T) {
+	local := setupSyncFQDN("example.com", nil)
+	local.flags.DNS = "override.com"
+	err := local.Run("maintenance")
+	assert.NoError(t, err)
+	assert.Equal(t, "host.override.com", hostFQDN.FQDN)
+}
+func TestSyncHostFQDNNoSuffix(t *testing.T) {
+	local := setupSyncFQDN("", nil)
+	err := local.Run("maintenance")
+	assert.Error(t, err)
+	assert.Equal(t, "", hostFQDN.FQDN)
+}
+func TestSyncHostFQDNFails(t *testing.T) {
+	local := setupSyncFQDN("example.com", errors.New("amt returned status 1"))
+	err := local.Run("maintenance")
+	assert.EqualError(t, err, "unable to set host fqdn: amt returned status 1")
+}
+
+func setupEnterpriseAccess(flagIP string, osIP net.IP, err error) LocalConfiguration {
+	osIPAddress = osIP
+	enterpriseAccessIP = nil
+	enterpriseAccess = false
+	enterpriseAccessErr = err
+	return NewLocalConfiguration(rpc.Flags{SubCommand: "enterprise-access", HostIP: flagIP, EnterpriseAccess: true}, MockAMT{})
+}
+
+func TestConfigureEnterpriseAccess(t *testing.T) {
+	local := setupEnterpriseAccess("192.168.1.100", net.ParseIP("10.0.0.1"), nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.100", enterpriseAccessIP.String())
+	assert.True(t, enterpriseAccess)
+}
+func TestConfigureEnterpriseAccessFromOS(t *testing.T) {
+	local := setupEnterpriseAccess("", net.ParseIP("10.0.0.1"), nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", enterpriseAccessIP.String())
+}
+func TestConfigureEnterpriseAccessNoOSAddress(t *testing.T) {
+	local := setupEnterpriseAccess("", nil, nil)
+	err := local.Run("configure")
+	assert.Error(t, err)
+	assert.Nil(t, enterpriseAccessIP)
+}
+func TestConfigureEnterpriseAccessFails(t *testing.T) {
+	local := setupEnterpriseAccess("192.168.1.100", nil, errors.New("amt returned status 1"))
+	err := local.Run("configure")
+	assert.EqualError(t, err, "unable to set enterprise access: amt returned status 1")
+}
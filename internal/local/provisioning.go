@@ -0,0 +1,65 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"fmt"
+	"rpc/pkg/pthi"
+	"rpc/pkg/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const otpMinLength = 8
+
+// Provisioning starts or stops provisioning mode, or sets the provisioning one time password
+func (local LocalConfiguration) Provisioning() error {
+	state, err := local.amt.GetProvisioningState()
+	if err != nil {
+		return err
+	}
+	if state == 2 {
+		return errors.New("device is already provisioned, use deactivate first")
+	}
+	switch local.flags.SubCommand {
+	case "start":
+		if state == 1 {
+			log.Info("Status: Device is already in-provisioning")
+			return nil
+		}
+		err = local.amt.StartConfiguration()
+		if err != nil {
+			return fmt.Errorf("unable to start configuration: %w", err)
+		}
+	case "stop":
+		if state == 0 {
+			log.Info("Status: Device is already pre-provisioning")
+			return nil
+		}
+		err = local.amt.StopConfiguration()
+		if err != nil {
+			return fmt.Errorf("unable to stop configuration: %w", err)
+		}
+	case "set-otp":
+		if len(local.flags.OTP) < otpMinLength || len(local.flags.OTP) > pthi.PROVISIONING_OTP_MAX_LENGTH {
+			return fmt.Errorf("one time password must be between %d and %d characters", otpMinLength, pthi.PROVISIONING_OTP_MAX_LENGTH)
+		}
+		err = local.amt.SetProvisioningServerOTP(local.flags.OTP)
+		if err != nil {
+			return fmt.Errorf("unable to set one time password: %w", err)
+		}
+		log.Info("Status: One time password set")
+		return nil
+	default:
+		return errors.New("unknown provisioning command: " + local.flags.SubCommand)
+	}
+	state, err = local.amt.GetProvisioningState()
+	if err != nil {
+		return err
+	}
+	log.Info("Status: Device is " + utils.InterpretProvisioningState(state))
+	return nil
+}
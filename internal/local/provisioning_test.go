@@ -0,0 +1,61 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"rpc/internal/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupProvisioning(subCommand string, state int, err error) LocalConfiguration {
+	provisioningState = state
+	configurationErr = err
+	otp = ""
+	return NewLocalConfiguration(rpc.Flags{SubCommand: subCommand, OTP: "P@ssw0rd"}, MockAMT{})
+}
+
+func TestProvisioningStart(t *testing.T) {
+	local := setupProvisioning("start", 0, nil)
+	err := local.Run("provisioning")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, provisioningState)
+}
+func TestProvisioningStop(t *testing.T) {
+	local := setupProvisioning("stop", 1, nil)
+	err := local.Run("provisioning")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, provisioningState)
+}
+func TestProvisioningStopAlreadyStopped(t *testing.T) {
+	local := setupProvisioning("stop", 0, errors.New("amt returned status 15"))
+	err := local.Run("provisioning")
+	assert.NoError(t, err)
+}
+func TestProvisioningStopFails(t *testing.T) {
+	local := setupProvisioning("stop", 1, errors.New("amt returned status 15"))
+	err := local.Run("provisioning")
+	assert.EqualError(t, err, "unable to stop configuration: amt returned status 15")
+}
+func TestProvisioningPostProvisioning(t *testing.T) {
+	local := setupProvisioning("start", 2, nil)
+	err := local.Run("provisioning")
+	assert.Error(t, err)
+}
+func TestProvisioningSetOTP(t *testing.T) {
+	local := setupProvisioning("set-otp", 0, nil)
+	err := local.Run("provisioning")
+	assert.NoError(t, err)
+	assert.Equal(t, "P@ssw0rd", otp)
+}
+func TestProvisioningSetOTPTooShort(t *testing.T) {
+	local := setupProvisioning("set-otp", 0, nil)
+	local.flags.OTP = "short"
+	err := local.Run("provisioning")
+	assert.Error(t, err)
+	assert.Equal(t, "", otp)
+}
@@ -0,0 +1,54 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"fmt"
+	"rpc/pkg/utils"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rngPollInterval is how often the RNG seed status is read while waiting for the seed
+var rngPollInterval = time.Second
+
+// ConfigureRNGSeed generates the RNG seed AMT needs for TLS and waits until it exists.
+// Nothing is generated when the seed already exists.
+func (local LocalConfiguration) ConfigureRNGSeed() error {
+	status, err := local.amt.GetRNGSeedStatus()
+	if err != nil {
+		return fmt.Errorf("unable to read rng seed status: %w", err)
+	}
+	if status == 0 {
+		log.Info("Status: RNG seed already exists")
+		return nil
+	}
+	if status != 1 {
+		err = local.amt.GenerateRNGSeed()
+		if err != nil {
+			return fmt.Errorf("unable to generate rng seed: %w", err)
+		}
+	}
+	timeout := time.After(local.flags.Timeout)
+	ticker := time.NewTicker(rngPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err = local.amt.GetRNGSeedStatus()
+		if err != nil {
+			return fmt.Errorf("unable to read rng seed status: %w", err)
+		}
+		if status == 0 {
+			log.Info("Status: RNG seed exists")
+			return nil
+		}
+		select {
+		case <-timeout:
+			return errors.New("timed out waiting for the rng seed, last status was '" + utils.InterpretRNGSeedStatus(status) + "'")
+		case <-ticker.C:
+		}
+	}
+}
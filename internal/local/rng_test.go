@@ -0,0 +1,51 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package local
+
+import (
+	"errors"
+	"rpc/internal/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRNGSeed(statuses []int, err error) LocalConfiguration {
+	rngPollInterval = time.Millisecond
+	rngStatuses = statuses
+	generateRNGSeedErr = err
+	generateRNGSeedCalled = false
+	return NewLocalConfiguration(rpc.Flags{SubCommand: "rngseed", Timeout: 50 * time.Millisecond}, MockAMT{})
+}
+
+func TestConfigureRNGSeed(t *testing.T) {
+	local := setupRNGSeed([]int{2, 1, 1, 0}, nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.True(t, generateRNGSeedCalled)
+}
+func TestConfigureRNGSeedExists(t *testing.T) {
+	local := setupRNGSeed([]int{0}, nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.False(t, generateRNGSeedCalled)
+}
+func TestConfigureRNGSeedInProgress(t *testing.T) {
+	local := setupRNGSeed([]int{1, 0}, nil)
+	err := local.Run("configure")
+	assert.NoError(t, err)
+	assert.False(t, generateRNGSeedCalled)
+}
+func TestConfigureRNGSeedTimeout(t *testing.T) {
+	local := setupRNGSeed([]int{2, 1}, nil)
+	err := local.Run("configure")
+	assert.EqualError(t, err, "timed out waiting for the rng seed, last status was 'in progress'")
+}
+func TestConfigureRNGSeedFails(t *testing.T) {
+	local := setupRNGSeed([]int{2}, errors.New("amt returned status 1"))
+	err := local.Run("configure")
+	assert.EqualError(t, err, "unable to generate rng seed: amt returned status 1")
+}
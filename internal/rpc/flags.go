@@ -5,34 +5,53 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"rpc/internal/amt"
+	"rpc/pkg/heci"
 	"rpc/pkg/utils"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
 // Flags holds data received from the command line
 type Flags struct {
-	commandLineArgs       []string
-	URL                   string
-	DNS                   string
-	Hostname              string
-	Proxy                 string
-	Command               string
-	Profile               string
-	SkipCertCheck         bool
-	Verbose               bool
-	SyncClock             bool
-	Password              string
-	amtInfoCommand        *flag.FlagSet
-	amtActivateCommand    *flag.FlagSet
-	amtDeactivateCommand  *flag.FlagSet
-	amtMaintenanceCommand *flag.FlagSet
+	commandLineArgs  []string
+	URL              string
+	DNS              string
+	Hostname         string
+	Proxy            string
+	Command          string
+	SubCommand       string
+	Profile          string
+	SkipCertCheck    bool
+	Verbose          bool
+	SyncClock        bool
+	SyncFQDN         bool
+	Timeout          time.Duration
+	OTP              string
+	HostIP           string
+	EnterpriseAccess bool
+	Local            bool
+	Password         string
+	// AccessCheck is called once a command has parsed its flags, before it prompts for input or talks to AMT
+	AccessCheck            func()
+	amtInfoCommand         *flag.FlagSet
+	amtActivateCommand     *flag.FlagSet
+	amtDeactivateCommand   *flag.FlagSet
+	amtMaintenanceCommand  *flag.FlagSet
+	amtConfigureCommand    *flag.FlagSet
+	amtCIRACommand         *flag.FlagSet
+	amtProvisioningCommand *flag.FlagSet
 }
 
 func NewFlags(args []string) *Flags {
@@ -42,10 +61,99 @@ func NewFlags(args []string) *Flags {
 	flags.amtActivateCommand = flag.NewFlagSet("activate", flag.ExitOnError)
 	flags.amtDeactivateCommand = flag.NewFlagSet("deactivate", flag.ExitOnError)
 	flags.amtMaintenanceCommand = flag.NewFlagSet("maintenance", flag.ExitOnError)
+	flags.amtConfigureCommand = flag.NewFlagSet("configure", flag.ExitOnError)
+	flags.amtCIRACommand = flag.NewFlagSet("cira", flag.ExitOnError)
+	flags.amtProvisioningCommand = flag.NewFlagSet("provisioning", flag.ExitOnError)
 	flags.setupCommonFlags()
 	return flags
 }
 
+// PromptContext cancels prompts for input once it is done, main sets it to be done on an interrupt
+var PromptContext = context.Background()
+
+// ReadSecret reads a line from stdin without echoing it when stdin is a terminal. It gives up with
+// the error of PromptContext once that is done, leaving the terminal as it found it.
+func ReadSecret() (string, error) {
+	fd := int(os.Stdin.Fd())
+	var state *term.State
+	if term.IsTerminal(fd) {
+		state, _ = term.GetState(fd)
+	}
+	type line struct {
+		secret string
+		err    error
+	}
+	read := make(chan line, 1)
+	go func() {
+		secret, err := readLine(fd, state != nil)
+		read <- line{secret, err}
+	}()
+	select {
+	case result := <-read:
+		return result.secret, result.err
+	case <-PromptContext.Done():
+		if state != nil {
+			// the abandoned read still has echo turned off
+			term.Restore(fd, state)
+			fmt.Println()
+		}
+		return "", PromptContext.Err()
+	}
+}
+
+// readLine reads a line from fd, through term.ReadPassword when it is a terminal
+func readLine(fd int, terminal bool) (string, error) {
+	if !terminal {
+		var secret string
+		_, err := fmt.Scanln(&secret)
+		return secret, err
+	}
+	secret, err := term.ReadPassword(fd)
+	defer func() {
+		for i := range secret {
+			secret[i] = 0
+		}
+	}()
+	fmt.Println()
+	return string(secret), err
+}
+
+// GlobalFlags holds the options given before the command
+type GlobalFlags struct {
+	HECITrace   string
+	HECIReplay  string
+	HECITimeout time.Duration
+	MEIDevice   string
+}
+
+// ParseGlobalFlags removes the global options from args, returning them and the remaining arguments
+func ParseGlobalFlags(args []string) (GlobalFlags, []string, error) {
+	global := GlobalFlags{HECITimeout: heci.ReadTimeout, MEIDevice: os.Getenv("RPC_MEI_DEVICE")}
+	if len(args) < 2 {
+		return global, args, nil
+	}
+	globalCommand := flag.NewFlagSet("rpc", flag.ContinueOnError)
+	globalCommand.SetOutput(ioutil.Discard)
+	globalCommand.StringVar(&global.HECITrace, "heci-trace", "", "record every HECI message to a file")
+	globalCommand.StringVar(&global.HECIReplay, "heci-replay", "", "answer HECI messages from a trace file")
+	globalCommand.DurationVar(&global.HECITimeout, "heci-timeout", global.HECITimeout, "how long to wait for the ME to respond")
+	globalCommand.StringVar(&global.MEIDevice, "mei-device", global.MEIDevice, "MEI device to open instead of discovering one")
+	err := globalCommand.Parse(args[1:])
+	if err == flag.ErrHelp {
+		return GlobalFlags{HECITimeout: heci.ReadTimeout, MEIDevice: os.Getenv("RPC_MEI_DEVICE")}, args, nil
+	}
+	if err != nil {
+		return global, args, err
+	}
+	if global.HECITimeout <= 0 {
+		return global, args, errors.New("--heci-timeout must be positive")
+	}
+	if global.HECITrace != "" && global.HECIReplay != "" {
+		return global, args, errors.New("--heci-trace and --heci-replay cannot be used together")
+	}
+	return global, append([]string{args[0]}, globalCommand.Args()...), nil
+}
+
 // ParseFlags is used for understanding the command line flags
 func (f *Flags) ParseFlags() (string, bool) {
 
@@ -54,6 +162,9 @@ func (f *Flags) ParseFlags() (string, bool) {
 		case "amtinfo":
 			f.handleAMTInfo(f.amtInfoCommand)
 			return "amtinfo", false //we want to exit the program
+		case "meiinfo":
+			f.handleMEIInfo()
+			return "meiinfo", false
 		case "activate":
 			success := f.handleActivateCommand()
 			return "activate", success
@@ -63,6 +174,15 @@ func (f *Flags) ParseFlags() (string, bool) {
 		case "deactivate":
 			success := f.handleDeactivateCommand()
 			return "deactivate", success
+		case "configure":
+			success := f.handleConfigureCommand()
+			return "configure", success
+		case "cira":
+			success := f.handleCIRACommand()
+			return "cira", success
+		case "provisioning":
+			success := f.handleProvisioningCommand()
+			return "provisioning", success
 		case "version":
 			println(strings.ToUpper(utils.ProjectName))
 			println("Version " + utils.ProjectVersion)
@@ -85,12 +205,32 @@ func (f *Flags) printUsage() string {
 	usage = usage + "              Example: ./rpc activate -u wss://server/activate --profile acmprofile\n"
 	usage = usage + "  deactivate  Deactivates this device. AMT password is required\n"
 	usage = usage + "              Example: ./rpc deactivate -u wss://server/activate\n"
+	usage = usage + "              Example: ./rpc deactivate -local\n"
 	usage = usage + "  maintenance Maintain this device.\n"
 	usage = usage + "              Example: ./rpc maintenance -u wss://server/activate\n"
+	usage = usage + "              Example: ./rpc maintenance -syncfqdn\n"
+	usage = usage + "  configure   Configure AMT settings locally over HECI, no server is required\n"
+	usage = usage + "              Example: ./rpc configure dnssuffix -d example.com\n"
+	usage = usage + "              Example: ./rpc configure enterprise-access -access=false\n"
+	usage = usage + "  cira        Opens or closes a user initiated CIRA connection to the MPS\n"
+	usage = usage + "              Example: ./rpc cira connect\n"
+	usage = usage + "  provisioning Starts or stops provisioning mode, or sets the provisioning one time password\n"
+	usage = usage + "              Example: ./rpc provisioning stop\n"
 	usage = usage + "  amtinfo     Displays information about AMT status and configuration\n"
 	usage = usage + "              Example: ./rpc amtinfo\n"
+	usage = usage + "  meiinfo     Displays the MEI devices, their firmware status and the ME clients they expose\n"
+	usage = usage + "              Example: ./rpc meiinfo\n"
 	usage = usage + "  version     Displays the current version of RPC and the RPC Protocol version\n"
 	usage = usage + "              Example: ./rpc version\n"
+	usage = usage + "\nGlobal Options:\n"
+	usage = usage + "  --heci-trace FILE   Records every HECI message to FILE, secrets are redacted\n"
+	usage = usage + "              Example: ./rpc --heci-trace trace.jsonl amtinfo\n"
+	usage = usage + "  --heci-replay FILE  Answers HECI messages from a trace instead of the device\n"
+	usage = usage + "              Example: ./rpc --heci-replay trace.jsonl amtinfo\n"
+	usage = usage + "  --heci-timeout DURATION  How long to wait for the ME to respond, 30s by default\n"
+	usage = usage + "              Example: ./rpc --heci-timeout 1m amtinfo\n"
+	usage = usage + "  --mei-device PATH   Opens PATH instead of the MEI device exposing AMT, also read from RPC_MEI_DEVICE\n"
+	usage = usage + "              Example: ./rpc --mei-device /dev/mei1 amtinfo\n"
 	usage = usage + "\nRun 'rpc COMMAND' for more information on a command.\n"
 	fmt.Println(usage)
 	return usage
@@ -107,13 +247,28 @@ func (f *Flags) setupCommonFlags() {
 func (f *Flags) handleMaintenanceCommand() bool {
 	f.amtActivateCommand.StringVar(&f.Password, "password", f.lookupEnvOrString("AMT_PASSWORD", ""), "AMT password")
 	f.amtMaintenanceCommand.BoolVar(&f.SyncClock, "c", false, "sync AMT clock")
+	f.amtMaintenanceCommand.BoolVar(&f.SyncFQDN, "syncfqdn", false, "sync the host FQDN stored in AMT with the OS over HECI, no server is required")
+	f.amtMaintenanceCommand.StringVar(&f.DNS, "d", f.lookupEnvOrString("DNS_SUFFIX", ""), "dns suffix override, used with -syncfqdn")
+	f.amtMaintenanceCommand.StringVar(&f.Hostname, "h", f.lookupEnvOrString("HOSTNAME", ""), "hostname override, used with -syncfqdn")
 
 	if len(f.commandLineArgs) == 2 {
 		f.amtMaintenanceCommand.PrintDefaults()
 		return false
 	}
 	f.amtMaintenanceCommand.Parse(f.commandLineArgs[2:])
+	f.checkAccess()
 	if f.amtMaintenanceCommand.Parsed() {
+		if f.SyncFQDN {
+			if f.URL != "" || f.SyncClock {
+				fmt.Println("-syncfqdn cannot be combined with -u or -c")
+				f.amtMaintenanceCommand.Usage()
+				return false
+			}
+			f.Local = true
+			f.SubCommand = "syncfqdn"
+			f.Command = "maintenance --syncfqdn"
+			return true
+		}
 		if f.URL == "" {
 			fmt.Println("-u flag is required and cannot be empty")
 			f.amtActivateCommand.Usage()
@@ -121,9 +276,7 @@ func (f *Flags) handleMaintenanceCommand() bool {
 		}
 		if f.Password == "" {
 			fmt.Println("Please enter AMT Password: ")
-			var password string
-			// Taking input from user
-			_, err := fmt.Scanln(&password)
+			password, err := ReadSecret()
 			if password == "" || err != nil {
 				return false
 			}
@@ -134,6 +287,106 @@ func (f *Flags) handleMaintenanceCommand() bool {
 	return true
 }
 
+func (f *Flags) handleConfigureCommand() bool {
+	f.amtConfigureCommand.BoolVar(&f.Verbose, "v", false, "verbose output")
+
+	if len(f.commandLineArgs) == 2 {
+		fmt.Println("Usage: rpc configure dnssuffix|enterprise-access|rngseed [OPTIONS]")
+		f.amtConfigureCommand.PrintDefaults()
+		return false
+	}
+	f.SubCommand = f.commandLineArgs[2]
+	switch f.SubCommand {
+	case "dnssuffix":
+		f.amtConfigureCommand.StringVar(&f.DNS, "d", f.lookupEnvOrString("DNS_SUFFIX", ""), "dns suffix, defaults to the suffix of the OS interface bound to AMT")
+	case "enterprise-access":
+		f.amtConfigureCommand.StringVar(&f.HostIP, "ip", "", "host ip address, defaults to the address of the OS interface bound to AMT")
+		f.amtConfigureCommand.BoolVar(&f.EnterpriseAccess, "access", true, "whether the host has access to the enterprise network")
+	case "rngseed":
+		f.amtConfigureCommand.DurationVar(&f.Timeout, "t", time.Minute, "how long to wait for the RNG seed to be generated")
+	default:
+		fmt.Println("unknown configure command: " + f.SubCommand)
+		return false
+	}
+	f.amtConfigureCommand.Parse(f.commandLineArgs[3:])
+	f.checkAccess()
+	if f.HostIP != "" && net.ParseIP(f.HostIP) == nil {
+		fmt.Println("invalid host ip address: " + f.HostIP)
+		return false
+	}
+	f.Local = true
+	f.Command = "configure " + f.SubCommand
+	return true
+}
+
+func (f *Flags) handleCIRACommand() bool {
+	f.amtCIRACommand.DurationVar(&f.Timeout, "t", 2*time.Minute, "how long to wait for the connection status to change")
+	f.amtCIRACommand.BoolVar(&f.Verbose, "v", false, "verbose output")
+
+	if len(f.commandLineArgs) == 2 {
+		fmt.Println("Usage: rpc cira connect|disconnect [OPTIONS]")
+		f.amtCIRACommand.PrintDefaults()
+		return false
+	}
+	f.SubCommand = f.commandLineArgs[2]
+	switch f.SubCommand {
+	case "connect", "disconnect":
+		f.amtCIRACommand.Parse(f.commandLineArgs[3:])
+		f.checkAccess()
+	default:
+		fmt.Println("unknown cira command: " + f.SubCommand)
+		return false
+	}
+	f.Local = true
+	f.Command = "cira " + f.SubCommand
+	return true
+}
+
+func (f *Flags) handleProvisioningCommand() bool {
+	f.amtProvisioningCommand.StringVar(&f.OTP, "otp", f.lookupEnvOrString("AMT_OTP", ""), "one time password, used with set-otp, prompted for when not given")
+	f.amtProvisioningCommand.BoolVar(&f.Verbose, "v", false, "verbose output")
+
+	if len(f.commandLineArgs) == 2 {
+		fmt.Println("Usage: rpc provisioning start|stop|set-otp [OPTIONS]")
+		f.amtProvisioningCommand.PrintDefaults()
+		return false
+	}
+	f.SubCommand = f.commandLineArgs[2]
+	switch f.SubCommand {
+	case "start", "stop", "set-otp":
+		f.amtProvisioningCommand.Parse(f.commandLineArgs[3:])
+		f.checkAccess()
+	default:
+		fmt.Println("unknown provisioning command: " + f.SubCommand)
+		return false
+	}
+	if f.SubCommand == "set-otp" {
+		f.amtProvisioningCommand.Visit(func(option *flag.Flag) {
+			if option.Name == "otp" {
+				log.Warn("the one time password given with -otp can be seen by other users, leave it out to be prompted for it")
+			}
+		})
+	}
+	if f.SubCommand == "set-otp" && f.OTP == "" {
+		fmt.Println("Please enter one time password: ")
+		otp, err := ReadSecret()
+		if otp == "" || err != nil {
+			return false
+		}
+		f.OTP = otp
+	}
+	f.Local = true
+	f.Command = "provisioning " + f.SubCommand
+	return true
+}
+
+// checkAccess runs AccessCheck, if one is set
+func (f *Flags) checkAccess() {
+	if f.AccessCheck != nil {
+		f.AccessCheck()
+	}
+}
+
 func (f *Flags) lookupEnvOrString(key string, defaultVal string) string {
 	if val, ok := os.LookupEnv(key); ok {
 		return val
@@ -163,6 +416,7 @@ func (f *Flags) handleActivateCommand() bool {
 		return false
 	}
 	f.amtActivateCommand.Parse(f.commandLineArgs[2:])
+	f.checkAccess()
 
 	if f.amtActivateCommand.Parsed() {
 		if f.URL == "" {
@@ -182,14 +436,25 @@ func (f *Flags) handleActivateCommand() bool {
 func (f *Flags) handleDeactivateCommand() bool {
 	f.amtDeactivateCommand.StringVar(&f.Password, "password", f.lookupEnvOrString("AMT_PASSWORD", ""), "AMT password")
 	forcePtr := f.amtDeactivateCommand.Bool("f", false, "force deactivate even if device is not registered with a server")
+	f.amtDeactivateCommand.BoolVar(&f.Local, "local", false, "deactivate a device in client control mode over HECI without a server")
 
 	if len(f.commandLineArgs) == 2 {
 		f.amtDeactivateCommand.PrintDefaults()
 		return false
 	}
 	f.amtDeactivateCommand.Parse(f.commandLineArgs[2:])
+	f.checkAccess()
 
 	if f.amtDeactivateCommand.Parsed() {
+		if f.Local {
+			if f.URL != "" {
+				fmt.Println("-u flag cannot be used with -local")
+				f.amtDeactivateCommand.Usage()
+				return false
+			}
+			f.Command = "deactivate --local"
+			return true
+		}
 		if f.URL == "" {
 			fmt.Println("-u flag is required and cannot be empty")
 			f.amtDeactivateCommand.Usage()
@@ -197,9 +462,7 @@ func (f *Flags) handleDeactivateCommand() bool {
 		}
 		if f.Password == "" {
 			fmt.Println("Please enter AMT Password: ")
-			var password string
-			// Taking input from user
-			_, err := fmt.Scanln(&password)
+			password, err := ReadSecret()
 			if password == "" || err != nil {
 				return false
 			}
@@ -212,32 +475,54 @@ func (f *Flags) handleDeactivateCommand() bool {
 	}
 	return true
 }
+
+// formatIP prints nothing instead of "<nil>" when an address could not be read
+func formatIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
 func (f *Flags) handleAMTInfo(amtInfoCommand *flag.FlagSet) {
 	amtInfoVerPtr := amtInfoCommand.Bool("ver", false, "BIOS Version")
 	amtInfoBldPtr := amtInfoCommand.Bool("bld", false, "Build Number")
 	amtInfoSkuPtr := amtInfoCommand.Bool("sku", false, "Product SKU")
 	amtInfoUUIDPtr := amtInfoCommand.Bool("uuid", false, "Unique Identifier")
 	amtInfoModePtr := amtInfoCommand.Bool("mode", false, "Current Control Mode")
+	amtInfoProvPtr := amtInfoCommand.Bool("prov", false, "Provisioning State and Mode")
 	amtInfoDNSPtr := amtInfoCommand.Bool("dns", false, "Domain Name Suffix")
+	amtInfoFQDNPtr := amtInfoCommand.Bool("fqdn", false, "Host FQDN Settings")
 	amtInfoCertPtr := amtInfoCommand.Bool("cert", false, "Certificate Hashes")
 	amtInfoRasPtr := amtInfoCommand.Bool("ras", false, "Remote Access Status")
 	amtInfoLanPtr := amtInfoCommand.Bool("lan", false, "LAN Settings")
 	amtInfoHostnamePtr := amtInfoCommand.Bool("hostname", false, "OS Hostname")
+	amtInfoFeaturesPtr := amtInfoCommand.Bool("features", false, "SOL/IDER Session, System Defense and Web UI State (GET_FEATURES_STATE does not report whether redirection or KVM are enabled)")
+	amtInfoResetPtr := amtInfoCommand.Bool("reset", false, "Last Host Reset Reason")
+	amtInfoPowerPtr := amtInfoCommand.Bool("power", false, "Current Power Policy")
+	amtInfoRNGPtr := amtInfoCommand.Bool("rng", false, "RNG Seed Status")
 	if len(f.commandLineArgs) == 2 {
 		*amtInfoVerPtr = true
 		*amtInfoBldPtr = true
 		*amtInfoSkuPtr = true
 		*amtInfoUUIDPtr = true
 		*amtInfoModePtr = true
+		*amtInfoProvPtr = true
 		*amtInfoDNSPtr = true
+		*amtInfoFQDNPtr = true
 		*amtInfoCertPtr = false
 		*amtInfoRasPtr = true
 		*amtInfoLanPtr = true
 		*amtInfoHostnamePtr = true
+		*amtInfoFeaturesPtr = true
+		*amtInfoResetPtr = true
+		*amtInfoPowerPtr = true
+		*amtInfoRNGPtr = true
 	}
 	amtInfoCommand.Parse(f.commandLineArgs[2:])
 
 	if amtInfoCommand.Parsed() {
+		f.checkAccess()
 		amt := amt.Command{}
 		if *amtInfoVerPtr {
 			result, _ := amt.GetVersionDataFromME("AMT")
@@ -259,11 +544,35 @@ func (f *Flags) handleAMTInfo(amtInfoCommand *flag.FlagSet) {
 			result, _ := amt.GetControlMode()
 			println("Control Mode		: " + string(utils.InterpretControlMode(result)))
 		}
+		if *amtInfoProvPtr {
+			state, _ := amt.GetProvisioningState()
+			println("Provisioning State	: " + utils.InterpretProvisioningState(state))
+			mode, _ := amt.GetProvisioningMode()
+			println("Provisioning Mode	: " + utils.InterpretProvisioningMode(mode))
+			tlsMode, _ := amt.GetProvisioningTLSMode()
+			println("Provisioning TLS Mode	: " + utils.InterpretProvisioningTLSMode(tlsMode))
+			zeroTouch, _ := amt.GetZeroTouchEnabled()
+			println("Zero Touch Enabled	: " + strconv.FormatBool(zeroTouch))
+			ehbc, err := amt.GetEHBCEnabled()
+			if err == nil {
+				println("Host Based Config	: " + utils.InterpretEHBCState(ehbc))
+			} else {
+				println("Host Based Config	: unknown")
+			}
+		}
 		if *amtInfoDNSPtr {
 			result, _ := amt.GetDNSSuffix()
 			println("DNS Suffix		: " + string(result))
 			result, _ = amt.GetOSDNSSuffix()
 			fmt.Println("DNS Suffix (OS)		: " + result)
+			suffixes, _ := amt.GetDNSSuffixList()
+			println("DNS Suffix List		: " + strings.Join(suffixes, ", "))
+		}
+		if *amtInfoFQDNPtr {
+			result, _ := amt.GetFQDN()
+			println("FQDN			: " + result.FQDN)
+			println("FQDN Shared		: " + strconv.FormatBool(result.Shared))
+			println("Dynamic DNS Update	: " + strconv.FormatBool(result.DDNSUpdateEnabled))
 		}
 		if *amtInfoHostnamePtr {
 			result, _ := os.Hostname()
@@ -283,16 +592,39 @@ func (f *Flags) handleAMTInfo(amtInfoCommand *flag.FlagSet) {
 			println("DHCP Enabled 		: " + strconv.FormatBool(wired.DHCPEnabled))
 			println("DHCP Mode    		: " + wired.DHCPMode)
 			println("Link Status  		: " + wired.LinkStatus)
-			println("IP Address   		: " + wired.IPAddress)
-			println("MAC Address  		: " + wired.MACAddress)
+			println("IP Address   		: " + formatIP(wired.IPAddress))
+			println("MAC Address  		: " + wired.MACAddress.String())
+
+			macAddresses, _ := amt.GetMACAddresses()
+			println("Dedicated MAC		: " + macAddresses.DedicatedMAC.String())
+			println("Host MAC     		: " + macAddresses.HostMAC.String())
 
 			wireless, _ := amt.GetLANInterfaceSettings(true)
 			println("---Wireless Adapter---")
 			println("DHCP Enabled 		: " + strconv.FormatBool(wireless.DHCPEnabled))
 			println("DHCP Mode    		: " + wireless.DHCPMode)
 			println("Link Status  		: " + wireless.LinkStatus)
-			println("IP Address   		: " + wireless.IPAddress)
-			println("MAC Address  		: " + wireless.MACAddress)
+			println("IP Address   		: " + formatIP(wireless.IPAddress))
+			println("MAC Address  		: " + wireless.MACAddress.String())
+		}
+		if *amtInfoFeaturesPtr {
+			result, _ := amt.GetFeaturesState()
+			println("SOL Session		: " + utils.InterpretSessionState(result.SOLOpen))
+			println("IDER Session		: " + utils.InterpretSessionState(result.IDEROpen))
+			println("System Defense		: " + utils.InterpretFeatureState(result.SystemDefenseActivated))
+			println("Web UI			: " + utils.InterpretFeatureState(result.WebUIEnabled))
+		}
+		if *amtInfoResetPtr {
+			result, _ := amt.GetLastHostResetReason()
+			println("Last Reset Reason	: " + utils.InterpretLastHostResetReason(result))
+		}
+		if *amtInfoPowerPtr {
+			result, _ := amt.GetCurrentPowerPolicy()
+			println("Power Policy		: " + utils.InterpretPowerPolicy(result))
+		}
+		if *amtInfoRNGPtr {
+			result, _ := amt.GetRNGSeedStatus()
+			println("RNG Seed		: " + utils.InterpretRNGSeedStatus(result))
 		}
 		if *amtInfoCertPtr {
 			result, _ := amt.GetCertificateHashes()
@@ -305,6 +637,8 @@ func (f *Flags) handleAMTInfo(amtInfoCommand *flag.FlagSet) {
 				}
 				if v.IsActive {
 					print("Active)")
+				} else {
+					print("Inactive)")
 				}
 				println()
 				println("   " + v.Algorithm + ": " + v.Hash)
@@ -312,3 +646,29 @@ func (f *Flags) handleAMTInfo(amtInfoCommand *flag.FlagSet) {
 		}
 	}
 }
+
+func (f *Flags) handleMEIInfo() {
+	devices, err := heci.ListMEIDevices()
+	if err != nil {
+		println("Unable to list MEI devices: " + err.Error())
+		return
+	}
+	if len(devices) == 0 {
+		println("No MEI devices found")
+		return
+	}
+	for _, device := range devices {
+		println("---" + device.Name + "---")
+		println("Device			: " + device.Path)
+		println("Driver			: " + device.Driver)
+		println("HBM Version		: " + device.HBMVersion)
+		println("HBM Version (Driver)	: " + device.DriverHBMVersion)
+		println("Firmware Version	: " + strings.Join(device.FirmwareVersions, ", "))
+		println("Firmware Status		: " + strings.Join(device.FirmwareStatus, " "))
+		println("AMTHI Client		: " + strconv.FormatBool(device.HasClient(heci.PTHIClientGUID)))
+		println("Clients			: " + strconv.Itoa(len(device.Clients)))
+		for _, client := range device.Clients {
+			println("  " + client.UUID + " version " + client.Version + ", max length " + client.MaxLength)
+		}
+	}
+}
@@ -5,9 +5,15 @@
 package rpc
 
 import (
+	"bytes"
+	"context"
+	"net"
 	"os"
+	"rpc/pkg/heci"
 	"testing"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,16 +33,110 @@ func TestPrintUsage(t *testing.T) {
 	usage = usage + "              Example: ./rpc activate -u wss://server/activate --profile acmprofile\n"
 	usage = usage + "  deactivate  Deactivates this device. AMT password is required\n"
 	usage = usage + "              Example: ./rpc deactivate -u wss://server/activate\n"
+	usage = usage + "              Example: ./rpc deactivate -local\n"
 	usage = usage + "  maintenance Maintain this device.\n"
 	usage = usage + "              Example: ./rpc maintenance -u wss://server/activate\n"
+	usage = usage + "              Example: ./rpc maintenance -syncfqdn\n"
+	usage = usage + "  configure   Configure AMT settings locally over HECI, no server is required\n"
+	usage = usage + "              Example: ./rpc configure dnssuffix -d example.com\n"
+	usage = usage + "              Example: ./rpc configure enterprise-access -access=false\n"
+	usage = usage + "  cira        Opens or closes a user initiated CIRA connection to the MPS\n"
+	usage = usage + "              Example: ./rpc cira connect\n"
+	usage = usage + "  provisioning Starts or stops provisioning mode, or sets the provisioning one time password\n"
+	usage = usage + "              Example: ./rpc provisioning stop\n"
 	usage = usage + "  amtinfo     Displays information about AMT status and configuration\n"
 	usage = usage + "              Example: ./rpc amtinfo\n"
+	usage = usage + "  meiinfo     Displays the MEI devices, their firmware status and the ME clients they expose\n"
+	usage = usage + "              Example: ./rpc meiinfo\n"
 	usage = usage + "  version     Displays the current version of RPC and the RPC Protocol version\n"
 	usage = usage + "              Example: ./rpc version\n"
+	usage = usage + "\nGlobal Options:\n"
+	usage = usage + "  --heci-trace FILE   Records every HECI message to FILE, secrets are redacted\n"
+	usage = usage + "              Example: ./rpc --heci-trace trace.jsonl amtinfo\n"
+	usage = usage + "  --heci-replay FILE  Answers HECI messages from a trace instead of the device\n"
+	usage = usage + "              Example: ./rpc --heci-replay trace.jsonl amtinfo\n"
+	usage = usage + "  --heci-timeout DURATION  How long to wait for the ME to respond, 30s by default\n"
+	usage = usage + "              Example: ./rpc --heci-timeout 1m amtinfo\n"
+	usage = usage + "  --mei-device PATH   Opens PATH instead of the MEI device exposing AMT, also read from RPC_MEI_DEVICE\n"
+	usage = usage + "              Example: ./rpc --mei-device /dev/mei1 amtinfo\n"
 	usage = usage + "\nRun 'rpc COMMAND' for more information on a command.\n"
 	assert.Equal(t, usage, output)
 }
 
+func TestParseGlobalFlagsNone(t *testing.T) {
+	args := []string{"./rpc", "amtinfo", "-uuid"}
+	global, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, GlobalFlags{HECITimeout: heci.ReadTimeout}, global)
+	assert.Equal(t, args, remaining)
+}
+func TestParseGlobalFlagsTrace(t *testing.T) {
+	args := []string{"./rpc", "--heci-trace", "trace.jsonl", "amtinfo", "-uuid"}
+	global, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, "trace.jsonl", global.HECITrace)
+	assert.Equal(t, []string{"./rpc", "amtinfo", "-uuid"}, remaining)
+}
+func TestParseGlobalFlagsReplay(t *testing.T) {
+	args := []string{"./rpc", "-heci-replay=trace.jsonl", "amtinfo"}
+	global, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, "trace.jsonl", global.HECIReplay)
+	assert.Equal(t, []string{"./rpc", "amtinfo"}, remaining)
+}
+func TestParseGlobalFlagsTraceAndReplay(t *testing.T) {
+	args := []string{"./rpc", "--heci-trace", "a.jsonl", "--heci-replay", "b.jsonl", "amtinfo"}
+	_, _, err := ParseGlobalFlags(args)
+	assert.EqualError(t, err, "--heci-trace and --heci-replay cannot be used together")
+}
+func TestParseGlobalFlagsMissingFile(t *testing.T) {
+	args := []string{"./rpc", "--heci-trace"}
+	_, _, err := ParseGlobalFlags(args)
+	assert.Error(t, err)
+}
+func TestParseGlobalFlagsMEIDevice(t *testing.T) {
+	args := []string{"./rpc", "--mei-device", "/dev/mei1", "amtinfo"}
+	global, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mei1", global.MEIDevice)
+	assert.Equal(t, []string{"./rpc", "amtinfo"}, remaining)
+}
+func TestParseGlobalFlagsMEIDeviceFromEnv(t *testing.T) {
+	os.Setenv("RPC_MEI_DEVICE", "/dev/mei2")
+	defer os.Unsetenv("RPC_MEI_DEVICE")
+	global, _, err := ParseGlobalFlags([]string{"./rpc", "amtinfo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mei2", global.MEIDevice)
+	global, _, err = ParseGlobalFlags([]string{"./rpc", "--mei-device", "/dev/mei1", "amtinfo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mei1", global.MEIDevice)
+}
+func TestParseFlagsMEIInfo(t *testing.T) {
+	args := []string{"./rpc", "meiinfo"}
+	flags := NewFlags(args)
+	command, result := flags.ParseFlags()
+	assert.Equal(t, "meiinfo", command)
+	assert.False(t, result)
+}
+func TestParseGlobalFlagsHECITimeout(t *testing.T) {
+	args := []string{"./rpc", "--heci-timeout", "90s", "amtinfo"}
+	global, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, global.HECITimeout)
+	assert.Equal(t, []string{"./rpc", "amtinfo"}, remaining)
+}
+func TestParseGlobalFlagsHECITimeoutInvalid(t *testing.T) {
+	args := []string{"./rpc", "--heci-timeout", "0s", "amtinfo"}
+	_, _, err := ParseGlobalFlags(args)
+	assert.EqualError(t, err, "--heci-timeout must be positive")
+}
+func TestParseGlobalFlagsHelp(t *testing.T) {
+	args := []string{"./rpc", "-h"}
+	_, remaining, err := ParseGlobalFlags(args)
+	assert.NoError(t, err)
+	assert.Equal(t, args, remaining)
+}
+
 func TestHandleActivateCommandNoFlags(t *testing.T) {
 	args := []string{"./rpc", "activate"}
 	flags := NewFlags(args)
@@ -170,6 +270,218 @@ func TestHandleDeactivateCommandWithForce(t *testing.T) {
 	assert.Equal(t, "wss://localhost", flags.URL)
 	assert.Equal(t, expected, flags.Command)
 }
+func TestHandleDeactivateCommandLocal(t *testing.T) {
+	args := []string{"./rpc", "deactivate", "-local"}
+	expected := "deactivate --local"
+	flags := NewFlags(args)
+	success := flags.handleDeactivateCommand()
+	assert.True(t, success)
+	assert.True(t, flags.Local)
+	assert.Equal(t, "", flags.Password)
+	assert.Equal(t, expected, flags.Command)
+}
+func TestHandleDeactivateCommandLocalWithURL(t *testing.T) {
+	args := []string{"./rpc", "deactivate", "-local", "-u", "wss://localhost"}
+	flags := NewFlags(args)
+	success := flags.handleDeactivateCommand()
+	assert.False(t, success)
+}
+func TestHandleConfigureCommandNoSubCommand(t *testing.T) {
+	args := []string{"./rpc", "configure"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.False(t, success)
+}
+func TestHandleConfigureCommandUnknown(t *testing.T) {
+	args := []string{"./rpc", "configure", "unknown"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.False(t, success)
+}
+func TestHandleConfigureDNSSuffix(t *testing.T) {
+	args := []string{"./rpc", "configure", "dnssuffix", "-d", "example.com"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.True(t, success)
+	assert.True(t, flags.Local)
+	assert.Equal(t, "dnssuffix", flags.SubCommand)
+	assert.Equal(t, "example.com", flags.DNS)
+}
+func TestHandleConfigureDNSSuffixDefault(t *testing.T) {
+	args := []string{"./rpc", "configure", "dnssuffix"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.True(t, success)
+	assert.Equal(t, "", flags.DNS)
+}
+func TestHandleConfigureEnterpriseAccess(t *testing.T) {
+	args := []string{"./rpc", "configure", "enterprise-access", "-ip", "192.168.1.100", "-access=false"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.True(t, success)
+	assert.Equal(t, "enterprise-access", flags.SubCommand)
+	assert.Equal(t, "192.168.1.100", flags.HostIP)
+	assert.False(t, flags.EnterpriseAccess)
+}
+func TestHandleConfigureEnterpriseAccessDefault(t *testing.T) {
+	args := []string{"./rpc", "configure", "enterprise-access"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.True(t, success)
+	assert.Equal(t, "", flags.HostIP)
+	assert.True(t, flags.EnterpriseAccess)
+}
+func TestHandleConfigureEnterpriseAccessInvalidIP(t *testing.T) {
+	args := []string{"./rpc", "configure", "enterprise-access", "-ip", "not-an-ip"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.False(t, success)
+}
+func TestHandleConfigureRNGSeed(t *testing.T) {
+	args := []string{"./rpc", "configure", "rngseed", "-t", "30s"}
+	flags := NewFlags(args)
+	success := flags.handleConfigureCommand()
+	assert.True(t, success)
+	assert.Equal(t, "rngseed", flags.SubCommand)
+	assert.Equal(t, 30*time.Second, flags.Timeout)
+}
+func TestHandleMaintenanceCommandSyncFQDN(t *testing.T) {
+	args := []string{"./rpc", "maintenance", "-syncfqdn", "-d", "example.com"}
+	flags := NewFlags(args)
+	success := flags.handleMaintenanceCommand()
+	assert.True(t, success)
+	assert.True(t, flags.Local)
+	assert.Equal(t, "syncfqdn", flags.SubCommand)
+	assert.Equal(t, "example.com", flags.DNS)
+	assert.Equal(t, "", flags.Password)
+}
+func TestHandleMaintenanceCommandSyncFQDNWithURL(t *testing.T) {
+	args := []string{"./rpc", "maintenance", "-syncfqdn", "-u", "wss://localhost"}
+	flags := NewFlags(args)
+	success := flags.handleMaintenanceCommand()
+	assert.False(t, success)
+}
+func TestHandleCIRACommandNoSubCommand(t *testing.T) {
+	args := []string{"./rpc", "cira"}
+	flags := NewFlags(args)
+	success := flags.handleCIRACommand()
+	assert.False(t, success)
+}
+func TestHandleCIRACommandConnect(t *testing.T) {
+	args := []string{"./rpc", "cira", "connect", "-t", "30s"}
+	flags := NewFlags(args)
+	success := flags.handleCIRACommand()
+	assert.True(t, success)
+	assert.True(t, flags.Local)
+	assert.Equal(t, "connect", flags.SubCommand)
+	assert.Equal(t, 30*time.Second, flags.Timeout)
+}
+func TestHandleCIRACommandDisconnect(t *testing.T) {
+	args := []string{"./rpc", "cira", "disconnect"}
+	flags := NewFlags(args)
+	success := flags.handleCIRACommand()
+	assert.True(t, success)
+	assert.Equal(t, "disconnect", flags.SubCommand)
+	assert.Equal(t, 2*time.Minute, flags.Timeout)
+}
+func TestHandleCIRACommandUnknown(t *testing.T) {
+	args := []string{"./rpc", "cira", "reconnect"}
+	flags := NewFlags(args)
+	success := flags.handleCIRACommand()
+	assert.False(t, success)
+}
+func TestHandleProvisioningCommandNoSubCommand(t *testing.T) {
+	args := []string{"./rpc", "provisioning"}
+	flags := NewFlags(args)
+	success := flags.handleProvisioningCommand()
+	assert.False(t, success)
+}
+func TestHandleProvisioningCommandStop(t *testing.T) {
+	args := []string{"./rpc", "provisioning", "stop"}
+	flags := NewFlags(args)
+	success := flags.handleProvisioningCommand()
+	assert.True(t, success)
+	assert.True(t, flags.Local)
+	assert.Equal(t, "stop", flags.SubCommand)
+}
+func TestHandleProvisioningCommandSetOTP(t *testing.T) {
+	args := []string{"./rpc", "provisioning", "set-otp", "-otp", "P@ssw0rd"}
+	flags := NewFlags(args)
+	success := flags.handleProvisioningCommand()
+	assert.True(t, success)
+	assert.Equal(t, "set-otp", flags.SubCommand)
+	assert.Equal(t, "P@ssw0rd", flags.OTP)
+	assert.NotContains(t, flags.Command, "P@ssw0rd")
+}
+func TestHandleProvisioningCommandSetOTPWarns(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	args := []string{"./rpc", "provisioning", "set-otp", "-otp", "P@ssw0rd"}
+	flags := NewFlags(args)
+	assert.True(t, flags.handleProvisioningCommand())
+	assert.Contains(t, output.String(), "can be seen by other users")
+	assert.NotContains(t, output.String(), "P@ssw0rd")
+}
+func TestHandleProvisioningCommandSetOTPPrompt(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	args := []string{"./rpc", "provisioning", "set-otp"}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("P@ssw0rd\n")
+	w.Close()
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	os.Stdin = r
+	flags := NewFlags(args)
+	assert.True(t, flags.handleProvisioningCommand())
+	assert.Equal(t, "P@ssw0rd", flags.OTP)
+	assert.Empty(t, output.String())
+}
+func TestReadSecretCancelled(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	os.Stdin = r
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { PromptContext = context.Background() }()
+	PromptContext = ctx
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	secret, err := ReadSecret()
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, secret)
+}
+func TestHandleProvisioningCommandSetOTPPromptEmpty(t *testing.T) {
+	args := []string{"./rpc", "provisioning", "set-otp"}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	os.Stdin = r
+	flags := NewFlags(args)
+	success := flags.handleProvisioningCommand()
+	assert.False(t, success)
+}
+func TestHandleProvisioningCommandUnknown(t *testing.T) {
+	args := []string{"./rpc", "provisioning", "restart"}
+	flags := NewFlags(args)
+	success := flags.handleProvisioningCommand()
+	assert.False(t, success)
+}
 
 func TestParseFlagsDeactivate(t *testing.T) {
 	args := []string{"./rpc", "deactivate"}
@@ -186,6 +498,44 @@ func TestParseFlagsAMTInfo(t *testing.T) {
 	assert.False(t, result)
 	assert.Equal(t, "amtinfo", command)
 }
+func TestParseFlagsAMTInfoChecksAccess(t *testing.T) {
+	args := []string{"./rpc", "amtinfo", "-hostname"}
+	flags := NewFlags(args)
+	checked := 0
+	flags.AccessCheck = func() { checked++ }
+	flags.ParseFlags()
+	assert.Equal(t, 1, checked)
+}
+func TestParseFlagsChecksAccessBeforePrompting(t *testing.T) {
+	for _, args := range [][]string{
+		{"./rpc", "deactivate", "-u", "wss://localhost"},
+		{"./rpc", "maintenance", "-u", "wss://localhost"},
+		{"./rpc", "provisioning", "set-otp"},
+	} {
+		flags := NewFlags(args)
+		// the real check exits when AMT can't be reached, so nothing after it may run
+		flags.AccessCheck = func() { panic("no access") }
+		assert.PanicsWithValue(t, "no access", func() { flags.ParseFlags() }, args[1])
+	}
+}
+func TestParseFlagsConfigureChecksAccess(t *testing.T) {
+	args := []string{"./rpc", "configure", "rngseed"}
+	flags := NewFlags(args)
+	checked := 0
+	flags.AccessCheck = func() { checked++ }
+	_, result := flags.ParseFlags()
+	assert.True(t, result)
+	assert.Equal(t, 1, checked)
+}
+func TestParseFlagsVersionSkipsAccessCheck(t *testing.T) {
+	args := []string{"./rpc", "version"}
+	flags := NewFlags(args)
+	checked := 0
+	flags.AccessCheck = func() { checked++ }
+	command, _ := flags.ParseFlags()
+	assert.Equal(t, "version", command)
+	assert.Equal(t, 0, checked)
+}
 func TestParseFlagsActivate(t *testing.T) {
 	args := []string{"./rpc", "activate"}
 	flags := NewFlags(args)
@@ -252,3 +602,8 @@ func TestLookupEnvOrBool_EnvError(t *testing.T) {
 	result := flags.lookupEnvOrBool("SKIP_CERT_CHECK", false)
 	assert.Equal(t, false, result)
 }
+
+func TestFormatIP(t *testing.T) {
+	assert.Equal(t, "", formatIP(nil))
+	assert.Equal(t, "192.168.1.100", formatIP(net.IPv4(192, 168, 1, 100)))
+}
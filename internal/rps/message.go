@@ -7,11 +7,13 @@ package rps
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"rpc/internal/amt"
 	"rpc/internal/rpc"
 	"rpc/pkg/utils"
+
+	log "github.com/sirupsen/logrus"
 )
 
 type Payload struct {
@@ -83,24 +85,13 @@ func (p Payload) createPayload(dnsSuffix string, hostname string) (MessagePayloa
 	payload.Username = lsa.Username
 	payload.Password = lsa.Password
 
-	if dnsSuffix != "" {
-		payload.FQDN = dnsSuffix
-	} else {
-		payload.FQDN, err = p.AMT.GetDNSSuffix()
-		if payload.FQDN == "" {
-			payload.FQDN, _ = p.AMT.GetOSDNSSuffix()
-		}
-		if err != nil {
-			return payload, err
-		}
+	payload.FQDN, err = amt.DNSSuffix(p.AMT, dnsSuffix)
+	if err != nil {
+		return payload, err
 	}
-	if hostname != "" {
-		payload.Hostname = hostname
-	} else {
-		payload.Hostname, err = os.Hostname()
-		if err != nil {
-			return payload, err
-		}
+	payload.Hostname, err = amt.Hostname(hostname)
+	if err != nil {
+		return payload, err
 	}
 	payload.Client = utils.ClientName
 	hashes, err := p.AMT.GetCertificateHashes()
@@ -108,12 +99,36 @@ func (p Payload) createPayload(dnsSuffix string, hostname string) (MessagePayloa
 		return payload, err
 	}
 	for _, v := range hashes {
-		payload.CertificateHashes = append(payload.CertificateHashes, v.Hash)
+		if v.IsActive {
+			payload.CertificateHashes = append(payload.CertificateHashes, v.Hash)
+		}
 	}
 	return payload, nil
 
 }
 
+// ActivationPreflight checks that a device in pre-provisioning state allows host based configuration,
+// so an activation that is bound to fail stops before contacting RPS. Devices that are already activated
+// are left to RPS, as is firmware too old to report the EHBC state.
+func (p Payload) ActivationPreflight() error {
+	controlMode, err := p.AMT.GetControlMode()
+	if err != nil {
+		return err
+	}
+	if controlMode != 0 {
+		return nil
+	}
+	enabled, err := p.AMT.GetEHBCEnabled()
+	if err != nil {
+		log.Debug("unable to read EHBC state, skipping check: " + err.Error())
+		return nil
+	}
+	if !enabled {
+		return errors.New("host based configuration is not allowed on this device, enable it in MEBx before activating")
+	}
+	return nil
+}
+
 // CreateMessageRequest is used for assembling the message to request activation of a device
 func (p Payload) CreateMessageRequest(flags rpc.Flags) (RPSMessage, error) {
 	message := RPSMessage{
@@ -133,8 +148,7 @@ func (p Payload) CreateMessageRequest(flags rpc.Flags) (RPSMessage, error) {
 		if flags.Password == "" {
 			for flags.Password == "" {
 				fmt.Println("Please enter AMT Password: ")
-				// Taking input from user
-				_, err = fmt.Scanln(&flags.Password)
+				flags.Password, err = rpc.ReadSecret()
 				if err != nil {
 					return message, err
 				}
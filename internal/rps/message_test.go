@@ -7,6 +7,8 @@ package rps
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"net"
 	"os"
 	"rpc/internal/amt"
 	"rpc/internal/rpc"
@@ -21,6 +23,9 @@ type MockAMT struct{}
 
 var mebxDNSSuffix string
 var controlMode int = 0
+var ehbcEnabled bool = true
+var ehbcErr error
+var certHashes = []amt.CertHashEntry{}
 
 func (c MockAMT) Initialize() (bool, error) {
 	return true, nil
@@ -30,24 +35,47 @@ func (c MockAMT) GetUUID() (string, error)                        { return "123-
 func (c MockAMT) GetUUIDV2() (string, error)                      { return "", nil }
 func (c MockAMT) GetControlMode() (int, error)                    { return controlMode, nil }
 func (c MockAMT) GetControlModeV2() (int, error)                  { return controlMode, nil }
+func (c MockAMT) GetProvisioningState() (int, error)              { return 0, nil }
+func (c MockAMT) GetProvisioningMode() (int, error)               { return 0, nil }
+func (c MockAMT) GetProvisioningTLSMode() (int, error)            { return 0, nil }
+func (c MockAMT) GetZeroTouchEnabled() (bool, error)              { return false, nil }
+func (c MockAMT) GetEHBCEnabled() (bool, error)                   { return ehbcEnabled, ehbcErr }
+func (c MockAMT) GetFeaturesState() (amt.FeaturesState, error)    { return amt.FeaturesState{}, nil }
+func (c MockAMT) GetLastHostResetReason() (int, error)            { return 0, nil }
+func (c MockAMT) GetCurrentPowerPolicy() (string, error)          { return "", nil }
 func (c MockAMT) GetOSDNSSuffix() (string, error)                 { return "osdns", nil }
+func (c MockAMT) GetOSIPAddress() (net.IP, error)                 { return nil, nil }
+func (c MockAMT) GetMACAddresses() (amt.MACAddresses, error)      { return amt.MACAddresses{}, nil }
 func (c MockAMT) GetDNSSuffix() (string, error)                   { return mebxDNSSuffix, nil }
+func (c MockAMT) SetDNSSuffix(suffix string) error                { return nil }
+func (c MockAMT) GetDNSSuffixList() ([]string, error)             { return []string{mebxDNSSuffix}, nil }
+func (c MockAMT) GetFQDN() (amt.FQDN, error)                      { return amt.FQDN{}, nil }
+func (c MockAMT) SetHostFQDN(fqdn string) error                   { return nil }
 func (c MockAMT) GetCertificateHashes() ([]amt.CertHashEntry, error) {
-	return []amt.CertHashEntry{}, nil
+	return certHashes, nil
 }
 func (c MockAMT) GetRemoteAccessConnectionStatus() (amt.RemoteAccessStatus, error) {
 	return amt.RemoteAccessStatus{}, nil
 }
+func (c MockAMT) OpenUserInitiatedConnection() error  { return nil }
+func (c MockAMT) CloseUserInitiatedConnection() error { return nil }
 func (c MockAMT) GetLANInterfaceSettings(useWireless bool) (amt.InterfaceSettings, error) {
 	return amt.InterfaceSettings{}, nil
 }
+func (c MockAMT) GetRNGSeedStatus() (int, error)                                 { return 0, nil }
+func (c MockAMT) GenerateRNGSeed() error                                         { return nil }
+func (c MockAMT) SetEnterpriseAccess(hostIP net.IP, enterpriseAccess bool) error { return nil }
 func (c MockAMT) GetLocalSystemAccount() (amt.LocalSystemAccount, error) {
 	return amt.LocalSystemAccount{Username: "Username", Password: "Password"}, nil
 }
+func (c MockAMT) Unprovision() error                        { return nil }
+func (c MockAMT) StartConfiguration() error                 { return nil }
+func (c MockAMT) StopConfiguration() error                  { return nil }
+func (c MockAMT) SetProvisioningServerOTP(otp string) error { return nil }
 
 var p Payload
 
-func (c MockAMT) InitiateLMS() {}
+func (c MockAMT) InitiateLMS() error { return nil }
 
 func init() {
 	p = Payload{}
@@ -70,6 +98,16 @@ func TestCreatePayload(t *testing.T) {
 	assert.Len(t, result.CertificateHashes, 0)
 	assert.NoError(t, err)
 }
+func TestCreatePayloadSkipsInactiveHashes(t *testing.T) {
+	certHashes = []amt.CertHashEntry{
+		{Hash: "active", IsActive: true},
+		{Hash: "inactive", IsActive: false},
+	}
+	defer func() { certHashes = []amt.CertHashEntry{} }()
+	result, err := p.createPayload("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"active"}, result.CertificateHashes)
+}
 func TestCreatePayloadWithOSDNSSuffix(t *testing.T) {
 	mebxDNSSuffix = ""
 	result, err := p.createPayload("", "")
@@ -82,6 +120,32 @@ func TestCreatePayloadWithDNSSuffix(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "vprodemo.com", result.FQDN)
 }
+func TestActivationPreflight(t *testing.T) {
+	err := p.ActivationPreflight()
+	assert.NoError(t, err)
+}
+func TestActivationPreflightEHBCDisabled(t *testing.T) {
+	ehbcEnabled = false
+	defer func() { ehbcEnabled = true }()
+	err := p.ActivationPreflight()
+	assert.EqualError(t, err, "host based configuration is not allowed on this device, enable it in MEBx before activating")
+}
+func TestActivationPreflightEHBCUnsupported(t *testing.T) {
+	ehbcErr = errors.New("amt returned status 1")
+	defer func() { ehbcErr = nil }()
+	err := p.ActivationPreflight()
+	assert.NoError(t, err)
+}
+func TestActivationPreflightActivated(t *testing.T) {
+	controlMode = 1
+	ehbcEnabled = false
+	defer func() {
+		controlMode = 0
+		ehbcEnabled = true
+	}()
+	err := p.ActivationPreflight()
+	assert.NoError(t, err)
+}
 func TestCreateActivationRequestNoDNSSuffix(t *testing.T) {
 	flags := rpc.Flags{
 		Command: "method",
@@ -0,0 +1,66 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import "fmt"
+
+// AccessFailure classifies why the MEI device could not be used
+type AccessFailure int
+
+const (
+	// AccessFailed is any failure that is not classified further
+	AccessFailed AccessFailure = iota
+	// AccessNoDevice means the MEI device node does not exist
+	AccessNoDevice
+	// AccessNoDriver means the mei_me kernel module is not loaded
+	AccessNoDriver
+	// AccessDenied means the user may not open the MEI device
+	AccessDenied
+	// AccessNoAMTHI means the device does not expose the AMTHI client, as on non-vPro SKUs
+	AccessNoAMTHI
+	// AccessBusy means the device or the AMTHI client is in use by another process
+	AccessBusy
+)
+
+// AccessError is returned by Init when the MEI device cannot be opened or the AMTHI client
+// cannot be connected to
+type AccessError struct {
+	Failure AccessFailure
+	Device  string
+	// Group owns the device node, it is set for AccessDenied when it can be looked up
+	Group string
+	Err   error
+}
+
+func (e *AccessError) Error() string {
+	if e.Failure == AccessNoAMTHI || e.Failure == AccessBusy {
+		return fmt.Sprintf("unable to connect to the AMTHI client on %s: %v", e.Device, e.Err)
+	}
+	return fmt.Sprintf("unable to open %s: %v", e.Device, e.Err)
+}
+
+func (e *AccessError) Unwrap() error {
+	return e.Err
+}
+
+// Remediation tells the user how to resolve the failure
+func (e *AccessError) Remediation() string {
+	switch e.Failure {
+	case AccessNoDevice:
+		return "No MEI device was found at " + e.Device + ". Ensure that Intel ME is enabled in the BIOS, or select the device with --mei-device."
+	case AccessNoDriver:
+		return "The mei_me kernel module is not loaded. Load it with 'modprobe mei_me' and check that the MEI driver is installed."
+	case AccessDenied:
+		if e.Group != "" {
+			return "Permission denied opening " + e.Device + ". Run rpc as root, or add the user to the '" + e.Group + "' group that owns the device."
+		}
+		return "Permission denied opening " + e.Device + ". Run rpc as root or with administrator privileges."
+	case AccessNoAMTHI:
+		return "The AMTHI client is not available on " + e.Device + ". This device does not support Intel AMT, as on non-vPro SKUs, or AMT is disabled in MEBx."
+	case AccessBusy:
+		return e.Device + " is in use by another process, such as LMS or another instance of rpc. Try again once it has exited."
+	}
+	return "Please ensure that Intel ME is present, the MEI driver is installed and that this application is run with administrator or root privileges."
+}
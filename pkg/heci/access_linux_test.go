@@ -0,0 +1,82 @@
+//go:build linux
+// +build linux
+
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func classifyOpen(t *testing.T, errno syscall.Errno, driverLoaded bool) *AccessError {
+	original := SysfsRoot
+	SysfsRoot = t.TempDir()
+	defer func() { SysfsRoot = original }()
+	if driverLoaded {
+		assert.NoError(t, os.MkdirAll(filepath.Join(SysfsRoot, "module", "mei_me"), 0755))
+	}
+	err := classifyOpenError("/dev/mei0", &os.PathError{Op: "open", Path: "/dev/mei0", Err: errno})
+	accessErr := &AccessError{}
+	assert.True(t, errors.As(err, &accessErr))
+	assert.True(t, errors.Is(err, errno))
+	return accessErr
+}
+
+func TestClassifyOpenErrorNoDevice(t *testing.T) {
+	accessErr := classifyOpen(t, syscall.ENOENT, true)
+	assert.Equal(t, AccessNoDevice, accessErr.Failure)
+	assert.Equal(t, "unable to open /dev/mei0: open /dev/mei0: no such file or directory", accessErr.Error())
+	assert.Contains(t, accessErr.Remediation(), "--mei-device")
+}
+func TestClassifyOpenErrorNoDriver(t *testing.T) {
+	accessErr := classifyOpen(t, syscall.ENOENT, false)
+	assert.Equal(t, AccessNoDriver, accessErr.Failure)
+	assert.Contains(t, accessErr.Remediation(), "modprobe mei_me")
+}
+func TestClassifyOpenErrorDenied(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EACCES, syscall.EPERM} {
+		accessErr := classifyOpen(t, errno, true)
+		assert.Equal(t, AccessDenied, accessErr.Failure)
+		assert.Contains(t, accessErr.Remediation(), "Run rpc as root")
+	}
+}
+func TestClassifyOpenErrorBusy(t *testing.T) {
+	accessErr := classifyOpen(t, syscall.EBUSY, true)
+	assert.Equal(t, AccessBusy, accessErr.Failure)
+}
+func TestClassifyOpenErrorOther(t *testing.T) {
+	accessErr := classifyOpen(t, syscall.EIO, true)
+	assert.Equal(t, AccessFailed, accessErr.Failure)
+	assert.Contains(t, accessErr.Remediation(), "Intel ME is present")
+}
+
+func TestClassifyConnectError(t *testing.T) {
+	cases := map[syscall.Errno]AccessFailure{
+		syscall.ENOTTY: AccessNoAMTHI,
+		syscall.ENODEV: AccessNoAMTHI,
+		syscall.EBUSY:  AccessBusy,
+		syscall.EIO:    AccessFailed,
+	}
+	for errno, failure := range cases {
+		err := classifyConnectError("/dev/mei0", errno)
+		accessErr := &AccessError{}
+		assert.True(t, errors.As(err, &accessErr))
+		assert.Equal(t, failure, accessErr.Failure, errno.Error())
+	}
+	err := classifyConnectError("/dev/mei0", syscall.ENOTTY)
+	assert.EqualError(t, err, "unable to connect to the AMTHI client on /dev/mei0: inappropriate ioctl for device")
+	assert.Contains(t, err.(*AccessError).Remediation(), "non-vPro")
+}
+
+func TestDeviceGroup(t *testing.T) {
+	assert.Equal(t, "", deviceGroup(filepath.Join(t.TempDir(), "missing")))
+}
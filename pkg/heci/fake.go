@@ -0,0 +1,85 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// FakeBufferSize is the maximum message length a Fake reports unless BufferSize is set
+const FakeBufferSize = 5120
+
+// Fake is an in-memory Transport that answers each request with the responses scripted for the
+// command code at bytes 4 to 8 of a PTHI request header. Every request sent is recorded.
+type Fake struct {
+	BufferSize uint32
+	InitErr    error
+	SendErr    error
+	ReceiveErr error
+	Requests   [][]byte
+	Closed     bool
+	responses  map[uint32][][]byte
+	pending    []byte
+	unanswered uint32
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		responses: map[uint32][][]byte{},
+	}
+}
+
+// Respond scripts the responses to command. They are returned in order, the last one is repeated.
+func (fake *Fake) Respond(command uint32, responses ...[]byte) {
+	fake.responses[command] = responses
+}
+
+func (fake *Fake) Init() error {
+	return fake.InitErr
+}
+func (fake *Fake) GetBufferSize() uint32 {
+	if fake.BufferSize == 0 {
+		return FakeBufferSize
+	}
+	return fake.BufferSize
+}
+func (fake *Fake) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
+	if fake.SendErr != nil {
+		return 0, fake.SendErr
+	}
+	fake.Requests = append(fake.Requests, append([]byte{}, buffer...))
+	fake.pending = nil
+	fake.unanswered = 0
+	if len(buffer) < 8 {
+		return 0, errors.New("request header is truncated")
+	}
+	command := binary.LittleEndian.Uint32(buffer[4:8])
+	responses := fake.responses[command]
+	if len(responses) == 0 {
+		fake.unanswered = command
+		return uint32(len(buffer)), nil
+	}
+	fake.pending = responses[0]
+	if len(responses) > 1 {
+		fake.responses[command] = responses[1:]
+	}
+	return uint32(len(buffer)), nil
+}
+func (fake *Fake) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
+	if fake.ReceiveErr != nil {
+		return 0, fake.ReceiveErr
+	}
+	if fake.pending == nil {
+		return 0, fmt.Errorf("no response scripted for command 0x%08X", fake.unanswered)
+	}
+	read := copy(buffer, fake.pending)
+	fake.pending = nil
+	return uint32(read), nil
+}
+func (fake *Fake) Close() {
+	fake.Closed = true
+}
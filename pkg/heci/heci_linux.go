@@ -8,18 +8,29 @@
 package heci
 
 import (
-	"C"
 	"bytes"
+	"context"
 	"encoding/binary"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"syscall"
+	"time"
 	"unsafe"
 
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
+// Heci is the Transport for the MEI device. Reads give up with ErrTimeout after Timeout, or
+// ReadTimeout when it is not set, and with the context's error once Context, or ReadContext when
+// it is not set, is done.
 type Heci struct {
+	Timeout    time.Duration
+	Context    context.Context
 	meiDevice  *os.File
 	bufferSize uint32
 }
@@ -29,6 +40,8 @@ const (
 	IOCTL_MEI_CONNECT_CLIENT = 0xC0104801
 )
 
+var _ DeviceLocker = &Heci{}
+
 var MEI_IAMTHIF = [16]byte{0x28, 0x00, 0xf8, 0x12, 0xb7, 0xb4, 0x2d, 0x4b, 0xac, 0xa8, 0x46, 0xe0, 0xff, 0x65, 0x81, 0x4c}
 
 // uint8 == uchar
@@ -54,15 +67,24 @@ type CMEIConnectClientData struct {
 func (heci *Heci) Init() error {
 
 	var err error
-	heci.meiDevice, err = os.OpenFile(Device, syscall.O_RDWR, 0)
+	device := FindMEIDevice()
+	heci.meiDevice, err = os.OpenFile(device, syscall.O_RDWR, 0)
 	if err != nil {
-		log.Println("Cannot open MEI Device")
-		return err
+		return classifyOpenError(device, err)
+	}
+
+	err = heci.connect()
+	if err != nil {
+		return classifyConnectError(device, err)
 	}
+	return nil
+}
 
+// connect connects the open device to the AMTHI client
+func (heci *Heci) connect() error {
 	data := CMEIConnectClientData{}
 	data.data = MEI_IAMTHIF
-	err = Ioctl(heci.meiDevice.Fd(), IOCTL_MEI_CONNECT_CLIENT, uintptr(unsafe.Pointer(&data)))
+	err := Ioctl(heci.meiDevice.Fd(), IOCTL_MEI_CONNECT_CLIENT, uintptr(unsafe.Pointer(&data)))
 	if err != nil {
 		return err
 	}
@@ -76,12 +98,81 @@ func (heci *Heci) Init() error {
 
 	return nil
 }
+
+// FindMEIDevice returns the MEI device to open. MEIDevicePath is used when set, otherwise the first
+// device exposing the PTHI client, falling back to the first device found and then to Device.
+func FindMEIDevice() string {
+	if MEIDevicePath != "" {
+		return MEIDevicePath
+	}
+	devices, err := ListMEIDevices()
+	if err != nil || len(devices) == 0 {
+		return Device
+	}
+	for _, device := range devices {
+		if device.HasClient(PTHIClientGUID) {
+			return device.Path
+		}
+	}
+	return devices[0].Path
+}
+
+// classifyOpenError explains why device could not be opened
+func classifyOpenError(device string, err error) error {
+	accessErr := &AccessError{Failure: AccessFailed, Device: device, Err: err}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		accessErr.Failure = AccessNoDevice
+		if _, statErr := os.Stat(filepath.Join(SysfsRoot, "module", "mei_me")); statErr != nil {
+			accessErr.Failure = AccessNoDriver
+		}
+	case errors.Is(err, os.ErrPermission):
+		accessErr.Failure = AccessDenied
+		accessErr.Group = deviceGroup(device)
+	case errors.Is(err, unix.EBUSY):
+		accessErr.Failure = AccessBusy
+	}
+	return accessErr
+}
+
+// classifyConnectError explains why the AMTHI client on device could not be connected to
+func classifyConnectError(device string, err error) error {
+	accessErr := &AccessError{Failure: AccessFailed, Device: device, Err: err}
+	switch {
+	case errors.Is(err, unix.ENOTTY), errors.Is(err, unix.ENODEV):
+		accessErr.Failure = AccessNoAMTHI
+	case errors.Is(err, unix.EBUSY):
+		accessErr.Failure = AccessBusy
+	}
+	return accessErr
+}
+
+// deviceGroup returns the name of the group owning device, or an empty string if it is unknown
+func deviceGroup(device string) string {
+	info, err := os.Stat(device)
+	if err != nil {
+		return ""
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	group, err := user.LookupGroupId(strconv.Itoa(int(stat.Gid)))
+	if err != nil {
+		return ""
+	}
+	return group.Name
+}
+
 func (heci *Heci) GetBufferSize() uint32 {
 	return heci.bufferSize
 }
 func (heci *Heci) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
 
 	size, err := syscall.Write(int(heci.meiDevice.Fd()), buffer)
+	if errors.Is(err, unix.ENODEV) {
+		return 0, heci.reset(err)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -90,13 +181,77 @@ func (heci *Heci) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32,
 }
 func (heci *Heci) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
 
-	read, err := unix.Read(int(heci.meiDevice.Fd()), buffer)
+	ctx, deadline := readDeadline(heci.Context, heci.Timeout)
+	read, err := pollRead(ctx, deadline, int(heci.meiDevice.Fd()), buffer)
+	if errors.Is(err, unix.ENODEV) {
+		return 0, heci.reset(err)
+	}
 	if err != nil {
 		return 0, err
 	}
 	return uint32(read), nil
 }
 
+// reset connects to the AMTHI client again after err revealed that the ME was reset, which
+// disconnects it, and returns the error for the dropped request
+func (heci *Heci) reset(err error) error {
+	connectErr := heci.connect()
+	if connectErr != nil {
+		return fmt.Errorf("unable to reconnect after the ME was reset: %w", connectErr)
+	}
+	return &resetError{err: err}
+}
+
+// pollRead reads from fd once it is readable, giving up when ctx is done or deadline has passed
+func pollRead(ctx context.Context, deadline time.Time, fd int, buffer []byte) (int, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		wait, err := nextWait(ctx, deadline)
+		if err != nil {
+			return 0, err
+		}
+		ready, err := unix.Poll(fds, int((wait+time.Millisecond-1)/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ready > 0 {
+			// errors such as POLLERR after an ME reset are reported by the read
+			return unix.Read(fd, buffer)
+		}
+	}
+}
+
+// LockDevice takes an advisory lock on the device, waiting up to LockTimeout for other processes
+// to release it
+func (heci *Heci) LockDevice() error {
+	ctx, deadline := readDeadline(heci.Context, LockTimeout)
+	for waited := false; ; waited = true {
+		err := unix.Flock(int(heci.meiDevice.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err != unix.EWOULDBLOCK {
+			return err
+		}
+		if !waited {
+			log.Info("Waiting for another process to release the MEI device")
+		}
+		wait, err := nextWait(ctx, deadline)
+		if err == ErrTimeout {
+			return ErrDeviceBusy
+		}
+		if err != nil {
+			return err
+		}
+		time.Sleep(wait)
+	}
+}
+
+// UnlockDevice releases the lock taken by LockDevice
+func (heci *Heci) UnlockDevice() {
+	unix.Flock(int(heci.meiDevice.Fd()), unix.LOCK_UN)
+}
+
 func Ioctl(fd, op, arg uintptr) error {
 	_, _, ep := syscall.Syscall(syscall.SYS_IOCTL, fd, op, arg)
 	if ep != 0 {
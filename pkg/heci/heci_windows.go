@@ -9,27 +9,43 @@ package heci
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"runtime"
 	"syscall"
+	"time"
 	"unicode/utf16"
 	"unsafe"
 
 	setupapi "rpc/pkg/windows"
 
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/windows"
 )
 
 const FILE_DEVICE_HECI = 0x8000
 const METHOD_BUFFERED = 0
 
+// mutexName names the mutex every rpc process takes around an exchange, in place of the advisory
+// lock on the device taken on Linux
+const mutexName = `Global\rpc-mei-exchange`
+
+var _ DeviceLocker = &Heci{}
+
 func ctl_code(device_type, function, method, access uint32) uint32 {
 	return (device_type << 16) | (access << 14) | (function << 2) | method
 }
 
+// Heci is the Transport for the MEI device. Reads give up with ErrTimeout after Timeout, or
+// ReadTimeout when it is not set, and with the context's error once Context, or ReadContext when
+// it is not set, is done.
 type Heci struct {
+	Timeout    time.Duration
+	Context    context.Context
 	meiDevice  windows.Handle
+	mutex      windows.Handle
 	bufferSize uint32
 	GUID       windows.GUID
 	PTHIGUID   windows.GUID
@@ -250,9 +266,18 @@ func (heci *Heci) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32,
 	// if err != nil {
 	// 	return 0, err
 	// }
-	event, err := windows.WaitForSingleObject(overlapped.HEvent, windows.INFINITE)
-	if event == (uint32)(windows.WAIT_TIMEOUT) {
-		return 0, errors.New("wait timeout while sending data")
+	ctx, deadline := readDeadline(heci.Context, heci.Timeout)
+	for {
+		wait, waitErr := nextWait(ctx, deadline)
+		if waitErr != nil {
+			windows.CancelIoEx(heci.meiDevice, &overlapped)
+			windows.GetOverlappedResult(heci.meiDevice, &overlapped, done, true)
+			return 0, waitErr
+		}
+		event, _ := windows.WaitForSingleObject(overlapped.HEvent, uint32(wait/time.Millisecond))
+		if event != (uint32)(windows.WAIT_TIMEOUT) {
+			break
+		}
 	}
 
 	err = windows.GetOverlappedResult(heci.meiDevice, &overlapped, done, true)
@@ -262,7 +287,60 @@ func (heci *Heci) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32,
 	return *done, nil
 }
 
+// LockDevice takes the mutex shared by rpc processes, waiting up to LockTimeout for other
+// processes to release it. Windows mutexes are owned by a thread, so the calling goroutine stays
+// on its thread until UnlockDevice.
+func (heci *Heci) LockDevice() error {
+	if heci.mutex == 0 {
+		name, err := windows.UTF16PtrFromString(mutexName)
+		if err != nil {
+			return err
+		}
+		mutex, err := windows.CreateMutex(nil, false, name)
+		if err != nil && err != windows.ERROR_ALREADY_EXISTS {
+			return err
+		}
+		heci.mutex = mutex
+	}
+	runtime.LockOSThread()
+	ctx, deadline := readDeadline(heci.Context, LockTimeout)
+	wait := time.Duration(0)
+	for waited := false; ; waited = true {
+		// an abandoned mutex is owned by the caller just the same
+		event, err := windows.WaitForSingleObject(heci.mutex, uint32(wait/time.Millisecond))
+		if err != nil {
+			runtime.UnlockOSThread()
+			return err
+		}
+		if event != (uint32)(windows.WAIT_TIMEOUT) {
+			return nil
+		}
+		if !waited {
+			log.Info("Waiting for another process to release the MEI device")
+		}
+		wait, err = nextWait(ctx, deadline)
+		if err == ErrTimeout {
+			runtime.UnlockOSThread()
+			return ErrDeviceBusy
+		}
+		if err != nil {
+			runtime.UnlockOSThread()
+			return err
+		}
+	}
+}
+
+// UnlockDevice releases the mutex taken by LockDevice
+func (heci *Heci) UnlockDevice() {
+	windows.ReleaseMutex(heci.mutex)
+	runtime.UnlockOSThread()
+}
+
 func (heci *Heci) Close() {
+	if heci.mutex != 0 {
+		windows.CloseHandle(heci.mutex)
+		heci.mutex = 0
+	}
 	windows.CloseHandle(heci.meiDevice)
 	heci.bufferSize = 0
 }
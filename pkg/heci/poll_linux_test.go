@@ -0,0 +1,125 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPipe(t *testing.T) (*os.File, *os.File) {
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		reader.Close()
+		writer.Close()
+	})
+	return reader, writer
+}
+
+func TestPollRead(t *testing.T) {
+	reader, writer := newPipe(t)
+	writer.Write([]byte{1, 2, 3})
+	buffer := make([]byte, 16)
+	read, err := pollRead(context.Background(), time.Now().Add(time.Second), int(reader.Fd()), buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, buffer[:read])
+}
+func TestPollReadWaits(t *testing.T) {
+	reader, writer := newPipe(t)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		writer.Write([]byte{4})
+	}()
+	buffer := make([]byte, 16)
+	read, err := pollRead(context.Background(), time.Now().Add(5*time.Second), int(reader.Fd()), buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4}, buffer[:read])
+}
+func TestPollReadTimeout(t *testing.T) {
+	reader, _ := newPipe(t)
+	start := time.Now()
+	_, err := pollRead(context.Background(), time.Now().Add(50*time.Millisecond), int(reader.Fd()), make([]byte, 16))
+	assert.Equal(t, ErrTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+func TestPollReadCancel(t *testing.T) {
+	reader, _ := newPipe(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err := pollRead(ctx, time.Now().Add(time.Minute), int(reader.Fd()), make([]byte, 16))
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestReceiveMessageReadContext(t *testing.T) {
+	reader, _ := newPipe(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	original := ReadContext
+	ReadContext = ctx
+	defer func() { ReadContext = original }()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	heci := &Heci{meiDevice: reader}
+	start := time.Now()
+	_, err := heci.ReceiveMessage(make([]byte, 16), nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestReadDeadlineDefaults(t *testing.T) {
+	ctx, deadline := readDeadline(nil, 0)
+	assert.Equal(t, ReadContext, ctx)
+	assert.WithinDuration(t, time.Now().Add(ReadTimeout), deadline, time.Second)
+	_, deadline = readDeadline(context.Background(), time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+}
+func TestNextWait(t *testing.T) {
+	wait, err := nextWait(context.Background(), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, pollInterval, wait)
+	wait, err = nextWait(context.Background(), time.Now().Add(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, wait <= 10*time.Millisecond)
+	_, err = nextWait(context.Background(), time.Now().Add(-time.Millisecond))
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestLockDevice(t *testing.T) {
+	path := t.TempDir() + "/mei0"
+	first, err := os.Create(path)
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := os.Open(path)
+	assert.NoError(t, err)
+	defer second.Close()
+
+	lockTimeout := LockTimeout
+	defer func() { LockTimeout = lockTimeout }()
+	LockTimeout = 50 * time.Millisecond
+	holder := &Heci{meiDevice: first}
+	// the read timeout does not bound the wait for the lock
+	waiter := &Heci{meiDevice: second, Timeout: time.Hour}
+	assert.NoError(t, holder.LockDevice())
+	started := time.Now()
+	err = waiter.LockDevice()
+	assert.Equal(t, ErrDeviceBusy, err)
+	assert.False(t, errors.Is(err, ErrTimeout))
+	assert.True(t, time.Since(started) < time.Second)
+	holder.UnlockDevice()
+	assert.NoError(t, waiter.LockDevice())
+	waiter.UnlockDevice()
+}
@@ -0,0 +1,142 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"errors"
+	"sync"
+)
+
+// DeviceLocker is implemented by transports that can take an advisory lock on their device, so
+// that exchanges from other processes can't interleave with their own
+type DeviceLocker interface {
+	LockDevice() error
+	UnlockDevice()
+}
+
+// Session shares one connected Transport between all the PTHI commands of a process. The Transport
+// is connected by the first Init and stays open until the session is closed. Its connections are
+// DeviceLockers: locking one holds the session's mutex, and the device lock when the Transport is a
+// DeviceLocker, so that callers can keep other exchanges out between a request and its response.
+type Session struct {
+	newTransport func() Transport
+	lock         sync.Mutex
+	exchange     sync.Mutex
+	transport    Transport
+}
+
+// DefaultSession is the session NewTransport hands out connections to. Closing those connections,
+// as every command in pthi and internal/amt does, leaves the MEI device open for the next command;
+// whoever uses them must close DefaultSession once done with AMT, as rpc's main does on exit.
+var DefaultSession = NewSession(func() Transport {
+	return &Heci{}
+})
+
+func NewSession(newTransport func() Transport) *Session {
+	return &Session{
+		newTransport: newTransport,
+	}
+}
+
+// Transport returns a connection to the session. Closing it leaves the session open.
+func (session *Session) Transport() Transport {
+	return &sessionTransport{
+		session: session,
+	}
+}
+
+// connect returns the session's Transport, connecting it if this is the first use or the last attempt failed
+func (session *Session) connect() (Transport, error) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	if session.transport != nil {
+		return session.transport, nil
+	}
+	transport := session.newTransport()
+	err := transport.Init()
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	session.transport = transport
+	return transport, nil
+}
+
+// Close closes the session's Transport, the next Init connects again
+func (session *Session) Close() {
+	session.exchange.Lock()
+	defer session.exchange.Unlock()
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	if session.transport != nil {
+		session.transport.Close()
+		session.transport = nil
+	}
+}
+
+type sessionTransport struct {
+	session   *Session
+	transport Transport
+	locked    bool
+}
+
+func (connection *sessionTransport) Init() error {
+	transport, err := connection.session.connect()
+	connection.transport = transport
+	return err
+}
+func (connection *sessionTransport) GetBufferSize() uint32 {
+	if connection.transport == nil {
+		return 0
+	}
+	return connection.transport.GetBufferSize()
+}
+func (connection *sessionTransport) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
+	if connection.transport == nil {
+		return 0, errors.New("session is not connected")
+	}
+	return connection.transport.SendMessage(buffer, done)
+}
+func (connection *sessionTransport) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
+	if connection.transport == nil {
+		return 0, errors.New("session is not connected")
+	}
+	return connection.transport.ReceiveMessage(buffer, done)
+}
+func (connection *sessionTransport) Close() {
+	connection.UnlockDevice()
+}
+
+// LockDevice starts an exchange, keeping out those of other connections and processes until UnlockDevice
+func (connection *sessionTransport) LockDevice() error {
+	if connection.transport == nil {
+		return errors.New("session is not connected")
+	}
+	if connection.locked {
+		return nil
+	}
+	connection.session.exchange.Lock()
+	if locker, ok := connection.transport.(DeviceLocker); ok {
+		err := locker.LockDevice()
+		if err != nil {
+			connection.session.exchange.Unlock()
+			return err
+		}
+	}
+	connection.locked = true
+	return nil
+}
+
+// UnlockDevice ends the exchange in progress, if any
+func (connection *sessionTransport) UnlockDevice() {
+	if !connection.locked {
+		return
+	}
+	if locker, ok := connection.transport.(DeviceLocker); ok {
+		locker.UnlockDevice()
+	}
+	connection.locked = false
+	connection.session.exchange.Unlock()
+}
@@ -0,0 +1,151 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo is a Transport that answers each request with itself and fails if exchanges interleave
+type echo struct {
+	inits       int
+	closed      bool
+	locks       int
+	pending     []byte
+	interleaved bool
+}
+
+func (echo *echo) Init() error {
+	echo.inits++
+	return nil
+}
+func (echo *echo) GetBufferSize() uint32 { return FakeBufferSize }
+func (echo *echo) SendMessage(buffer []byte, done *uint32) (uint32, error) {
+	if echo.pending != nil {
+		echo.interleaved = true
+	}
+	echo.pending = append([]byte{}, buffer...)
+	return uint32(len(buffer)), nil
+}
+func (echo *echo) ReceiveMessage(buffer []byte, done *uint32) (uint32, error) {
+	// give other exchanges the chance to interleave
+	time.Sleep(time.Millisecond)
+	read := copy(buffer, echo.pending)
+	echo.pending = nil
+	return uint32(read), nil
+}
+func (echo *echo) Close() {
+	echo.closed = true
+}
+func (echo *echo) LockDevice() error {
+	echo.locks++
+	return nil
+}
+func (echo *echo) UnlockDevice() {
+	echo.locks--
+}
+
+// exchange sends value and checks it is echoed back, holding the device lock as PTHI commands do
+func exchange(t *testing.T, transport Transport, value uint32) {
+	locker := transport.(DeviceLocker)
+	assert.NoError(t, locker.LockDevice())
+	defer locker.UnlockDevice()
+	request := make([]byte, 4)
+	binary.LittleEndian.PutUint32(request, value)
+	_, err := transport.SendMessage(request, nil)
+	assert.NoError(t, err)
+	response := make([]byte, 4)
+	_, err = transport.ReceiveMessage(response, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, value, binary.LittleEndian.Uint32(response))
+}
+
+func TestSessionReusesConnection(t *testing.T) {
+	underlying := &echo{}
+	session := NewSession(func() Transport { return underlying })
+	for i := 0; i < 3; i++ {
+		transport := session.Transport()
+		assert.NoError(t, transport.Init())
+		assert.Equal(t, uint32(FakeBufferSize), transport.GetBufferSize())
+		exchange(t, transport, uint32(i))
+		transport.Close()
+	}
+	assert.Equal(t, 1, underlying.inits)
+	assert.False(t, underlying.closed)
+	session.Close()
+	assert.True(t, underlying.closed)
+}
+func TestSessionSerializesExchanges(t *testing.T) {
+	underlying := &echo{}
+	session := NewSession(func() Transport { return underlying })
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func(value uint32) {
+			defer wait.Done()
+			transport := session.Transport()
+			defer transport.Close()
+			assert.NoError(t, transport.Init())
+			exchange(t, transport, value)
+		}(uint32(i))
+	}
+	wait.Wait()
+	assert.False(t, underlying.interleaved)
+	assert.Equal(t, 0, underlying.locks)
+}
+func TestSessionLocksDevice(t *testing.T) {
+	underlying := &echo{}
+	session := NewSession(func() Transport { return underlying })
+	transport := session.Transport()
+	assert.NoError(t, transport.Init())
+	locker := transport.(DeviceLocker)
+	assert.NoError(t, locker.LockDevice())
+	assert.Equal(t, 1, underlying.locks)
+	locker.UnlockDevice()
+	assert.Equal(t, 0, underlying.locks)
+}
+func TestSessionSendDoesNotHoldLock(t *testing.T) {
+	underlying := &echo{}
+	session := NewSession(func() Transport { return underlying })
+	transport := session.Transport()
+	assert.NoError(t, transport.Init())
+	transport.SendMessage([]byte{1}, nil)
+	assert.Equal(t, 0, underlying.locks)
+	next := session.Transport()
+	assert.NoError(t, next.Init())
+	underlying.pending = nil
+	exchange(t, next, 7)
+}
+func TestSessionCloseReleasesExchange(t *testing.T) {
+	underlying := &echo{}
+	session := NewSession(func() Transport { return underlying })
+	transport := session.Transport()
+	assert.NoError(t, transport.Init())
+	assert.NoError(t, transport.(DeviceLocker).LockDevice())
+	transport.Close()
+	assert.Equal(t, 0, underlying.locks)
+	next := session.Transport()
+	assert.NoError(t, next.Init())
+	exchange(t, next, 7)
+}
+func TestSessionInitFailure(t *testing.T) {
+	fake := NewFake()
+	fake.InitErr = errors.New("no device")
+	session := NewSession(func() Transport { return fake })
+	transport := session.Transport()
+	assert.EqualError(t, transport.Init(), "no device")
+	assert.True(t, fake.Closed)
+	_, err := transport.SendMessage([]byte{1}, nil)
+	assert.EqualError(t, err, "session is not connected")
+
+	fake.InitErr = nil
+	assert.NoError(t, session.Transport().Init())
+}
@@ -0,0 +1,122 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SysfsRoot is where the MEI devices and clients are enumerated from
+var SysfsRoot = "/sys"
+
+// MEIDevicePath is the MEI device to open instead of discovering one, set from --mei-device or RPC_MEI_DEVICE
+var MEIDevicePath string
+
+// MEIDevice describes an MEI device found in /sys/class/mei
+type MEIDevice struct {
+	Name             string
+	Path             string
+	Driver           string
+	HBMVersion       string
+	DriverHBMVersion string
+	FirmwareVersions []string
+	FirmwareStatus   []string
+	Clients          []MEIClient
+}
+
+// MEIClient is an ME client found in /sys/bus/mei/devices
+type MEIClient struct {
+	UUID          string
+	Name          string
+	Version       string
+	MaxLength     string
+	MaxConnection string
+}
+
+// HasClient reports whether the device exposes the client with the given GUID
+func (device MEIDevice) HasClient(guid string) bool {
+	for _, client := range device.Clients {
+		if strings.EqualFold(client.UUID, guid) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListMEIDevices returns the MEI devices in sysfs sorted by name, with the clients each exposes
+func ListMEIDevices() ([]MEIDevice, error) {
+	paths, err := filepath.Glob(filepath.Join(SysfsRoot, "class", "mei", "mei*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	clients := listMEIClients()
+	devices := []MEIDevice{}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		device := MEIDevice{
+			Name:             name,
+			Path:             "/dev/" + name,
+			HBMVersion:       readSysfs(path, "hbm_ver"),
+			DriverHBMVersion: readSysfs(path, "hbm_ver_drv"),
+			FirmwareVersions: readSysfsLines(path, "fw_ver"),
+			FirmwareStatus:   readSysfsLines(path, "fw_status"),
+		}
+		driver, err := os.Readlink(filepath.Join(path, "device", "driver"))
+		if err == nil {
+			device.Driver = filepath.Base(driver)
+		}
+		// clients are named after the parent device of the MEI device, followed by their uuid
+		parent, err := filepath.EvalSymlinks(filepath.Join(path, "device"))
+		if err == nil {
+			device.Clients = clients[filepath.Base(parent)]
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// listMEIClients returns the clients in /sys/bus/mei/devices keyed by the name of their parent device
+func listMEIClients() map[string][]MEIClient {
+	clients := map[string][]MEIClient{}
+	paths, _ := filepath.Glob(filepath.Join(SysfsRoot, "bus", "mei", "devices", "*"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		uuid := readSysfs(path, "uuid")
+		if uuid == "" || !strings.HasSuffix(name, "-"+uuid) {
+			continue
+		}
+		parent := strings.TrimSuffix(name, "-"+uuid)
+		clients[parent] = append(clients[parent], MEIClient{
+			UUID:          uuid,
+			Name:          readSysfs(path, "name"),
+			Version:       readSysfs(path, "version"),
+			MaxLength:     readSysfs(path, "max_len"),
+			MaxConnection: readSysfs(path, "max_conn"),
+		})
+	}
+	return clients
+}
+
+func readSysfs(path string, attribute string) string {
+	data, err := ioutil.ReadFile(filepath.Join(path, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSysfsLines(path string, attribute string) []string {
+	value := readSysfs(path, attribute)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pthiClientUUID = "12f80028-b4b7-4b2d-aca8-46e0ff65814c"
+const mkhiClientUUID = "8e6a6715-9abc-4043-88ef-9e39c6f63e0f"
+
+func writeSysfs(t *testing.T, path string, attributes map[string]string) {
+	assert.NoError(t, os.MkdirAll(path, 0755))
+	for name, value := range attributes {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(path, name), []byte(value), 0644))
+	}
+}
+
+// setupSysfs creates two MEI devices where only the second one, mei1, exposes the PTHI client
+func setupSysfs(t *testing.T) {
+	root := t.TempDir()
+	original := SysfsRoot
+	SysfsRoot = root
+	t.Cleanup(func() { SysfsRoot = original })
+
+	driver := filepath.Join(root, "bus", "pci", "drivers", "mei_me")
+	writeSysfs(t, driver, nil)
+	for i, parent := range []string{"0000:00:16.0", "0000:00:16.4"} {
+		pci := filepath.Join(root, "devices", "pci0000:00", parent)
+		writeSysfs(t, pci, nil)
+		assert.NoError(t, os.Symlink(driver, filepath.Join(pci, "driver")))
+		mei := filepath.Join(root, "class", "mei", "mei"+string(rune('0'+i)))
+		writeSysfs(t, mei, map[string]string{
+			"hbm_ver":     "2.2\n",
+			"hbm_ver_drv": "2.2\n",
+			"fw_ver":      "0:15.0.10.1447\n0:15.0.10.1447\n",
+			"fw_status":   "94000245\n09F10506\n",
+		})
+		assert.NoError(t, os.Symlink(pci, filepath.Join(mei, "device")))
+	}
+	writeSysfs(t, filepath.Join(root, "bus", "mei", "devices", "0000:00:16.0-"+mkhiClientUUID), map[string]string{
+		"uuid":    mkhiClientUUID + "\n",
+		"version": "1\n",
+	})
+	writeSysfs(t, filepath.Join(root, "bus", "mei", "devices", "0000:00:16.4-"+pthiClientUUID), map[string]string{
+		"uuid":     pthiClientUUID + "\n",
+		"version":  "1\n",
+		"max_len":  "5120\n",
+		"max_conn": "1\n",
+	})
+}
+
+func TestListMEIDevices(t *testing.T) {
+	setupSysfs(t)
+	devices, err := ListMEIDevices()
+	assert.NoError(t, err)
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "mei0", devices[0].Name)
+	assert.Equal(t, "/dev/mei0", devices[0].Path)
+	assert.Equal(t, "mei_me", devices[0].Driver)
+	assert.Equal(t, "2.2", devices[0].HBMVersion)
+	assert.Equal(t, []string{"0:15.0.10.1447", "0:15.0.10.1447"}, devices[0].FirmwareVersions)
+	assert.Equal(t, []string{"94000245", "09F10506"}, devices[0].FirmwareStatus)
+	assert.Equal(t, []MEIClient{{UUID: mkhiClientUUID, Version: "1"}}, devices[0].Clients)
+	assert.False(t, devices[0].HasClient(PTHIClientGUID))
+	assert.True(t, devices[1].HasClient(PTHIClientGUID))
+	assert.Equal(t, "5120", devices[1].Clients[0].MaxLength)
+}
+func TestListMEIDevicesNone(t *testing.T) {
+	original := SysfsRoot
+	defer func() { SysfsRoot = original }()
+	SysfsRoot = t.TempDir()
+	devices, err := ListMEIDevices()
+	assert.NoError(t, err)
+	assert.Empty(t, devices)
+}
+
+func TestFindMEIDevice(t *testing.T) {
+	setupSysfs(t)
+	assert.Equal(t, "/dev/mei1", FindMEIDevice())
+}
+func TestFindMEIDeviceOverride(t *testing.T) {
+	setupSysfs(t)
+	MEIDevicePath = "/dev/mei7"
+	defer func() { MEIDevicePath = "" }()
+	assert.Equal(t, "/dev/mei7", FindMEIDevice())
+}
+func TestFindMEIDeviceWithoutPTHIClient(t *testing.T) {
+	setupSysfs(t)
+	os.RemoveAll(filepath.Join(SysfsRoot, "bus", "mei"))
+	assert.Equal(t, "/dev/mei0", FindMEIDevice())
+}
+func TestFindMEIDeviceDefault(t *testing.T) {
+	original := SysfsRoot
+	defer func() { SysfsRoot = original }()
+	SysfsRoot = t.TempDir()
+	assert.Equal(t, Device, FindMEIDevice())
+}
@@ -0,0 +1,317 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PTHIClientGUID identifies the ME client PTHI commands are sent to
+const PTHIClientGUID = "12F80028-B4B7-4B2D-ACA8-46E0FF65814C"
+
+// Operations recorded in a trace
+const (
+	TraceInit    = "init"
+	TraceSend    = "send"
+	TraceReceive = "receive"
+	TraceClose   = "close"
+)
+
+// Kinds of error recorded in a trace, so that a replay returns an error of the same type
+const (
+	TraceErrorAccess  = "access"
+	TraceErrorTimeout = "timeout"
+	TraceErrorReset   = "reset"
+	TraceErrorErrno   = "errno"
+)
+
+// TraceRecord is a single line of a trace file. Data is the hex encoded message, Redacted is set
+// when secrets were removed from it before it was written. ErrorKind, Errno and Access describe
+// Error beyond its message.
+type TraceRecord struct {
+	Time       time.Time         `json:"time"`
+	GUID       string            `json:"guid"`
+	Op         string            `json:"op"`
+	BufferSize uint32            `json:"bufferSize,omitempty"`
+	Data       string            `json:"data,omitempty"`
+	Redacted   bool              `json:"redacted,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ErrorKind  string            `json:"errorKind,omitempty"`
+	Errno      uint32            `json:"errno,omitempty"`
+	Access     *TraceAccessError `json:"access,omitempty"`
+}
+
+// TraceAccessError holds the fields of an AccessError, Cause being the message of the error it wraps
+type TraceAccessError struct {
+	Failure AccessFailure `json:"failure"`
+	Device  string        `json:"device"`
+	Group   string        `json:"group,omitempty"`
+	Cause   string        `json:"cause"`
+}
+
+// tracedError is an error read from a trace. It keeps the recorded message and unwraps to the
+// recorded errno, if there was one.
+type tracedError struct {
+	message string
+	errno   syscall.Errno
+}
+
+func (e *tracedError) Error() string {
+	return e.message
+}
+
+func (e *tracedError) Unwrap() error {
+	if e.errno == 0 {
+		return nil
+	}
+	return e.errno
+}
+
+// setError records err in record
+func (record *TraceRecord) setError(err error) {
+	record.Error = err.Error()
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		record.Errno = uint32(errno)
+	}
+	accessErr := &AccessError{}
+	switch {
+	case errors.As(err, &accessErr):
+		record.ErrorKind = TraceErrorAccess
+		record.Access = &TraceAccessError{
+			Failure: accessErr.Failure,
+			Device:  accessErr.Device,
+			Group:   accessErr.Group,
+			Cause:   fmt.Sprint(accessErr.Err),
+		}
+	case errors.Is(err, ErrTimeout):
+		record.ErrorKind = TraceErrorTimeout
+	case errors.Is(err, ErrMEReset):
+		record.ErrorKind = TraceErrorReset
+	case errno != 0:
+		record.ErrorKind = TraceErrorErrno
+	}
+}
+
+// err rebuilds the error recorded in record, or returns nil if it has none
+func (record *TraceRecord) err() error {
+	if record.Error == "" {
+		return nil
+	}
+	switch {
+	case record.ErrorKind == TraceErrorAccess && record.Access != nil:
+		return &AccessError{
+			Failure: record.Access.Failure,
+			Device:  record.Access.Device,
+			Group:   record.Access.Group,
+			Err:     &tracedError{message: record.Access.Cause, errno: syscall.Errno(record.Errno)},
+		}
+	case record.ErrorKind == TraceErrorTimeout:
+		return ErrTimeout
+	case record.ErrorKind == TraceErrorReset:
+		message := strings.TrimPrefix(record.Error, ErrMEReset.Error()+": ")
+		return &resetError{err: &tracedError{message: message, errno: syscall.Errno(record.Errno)}}
+	}
+	return &tracedError{message: record.Error, errno: syscall.Errno(record.Errno)}
+}
+
+// Tracer writes the messages exchanged by the transports it wraps to a trace, one JSON record per line
+type Tracer struct {
+	// Redact returns a copy of data with any secrets removed, or false if there is nothing to remove
+	Redact func(op string, data []byte) ([]byte, bool)
+	guid   string
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func NewTracer(writer io.Writer, guid string) *Tracer {
+	return &Tracer{
+		guid:   guid,
+		writer: writer,
+	}
+}
+
+// Wrap returns a Transport that records everything exchanged over transport
+func (tracer *Tracer) Wrap(transport Transport) Transport {
+	return &recorder{
+		transport: transport,
+		tracer:    tracer,
+	}
+}
+
+func (tracer *Tracer) write(record TraceRecord, data []byte, err error) {
+	record.Time = time.Now()
+	record.GUID = tracer.guid
+	if data != nil {
+		if tracer.Redact != nil {
+			if redacted, ok := tracer.Redact(record.Op, data); ok {
+				data = redacted
+				record.Redacted = true
+			}
+		}
+		record.Data = hex.EncodeToString(data)
+	}
+	if err != nil {
+		record.setError(err)
+	}
+	line, _ := json.Marshal(record)
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	tracer.writer.Write(append(line, '\n'))
+}
+
+type recorder struct {
+	transport Transport
+	tracer    *Tracer
+}
+
+func (recorder *recorder) Init() error {
+	err := recorder.transport.Init()
+	recorder.tracer.write(TraceRecord{Op: TraceInit, BufferSize: recorder.transport.GetBufferSize()}, nil, err)
+	return err
+}
+func (recorder *recorder) GetBufferSize() uint32 {
+	return recorder.transport.GetBufferSize()
+}
+func (recorder *recorder) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
+	bytesWritten, err = recorder.transport.SendMessage(buffer, done)
+	recorder.tracer.write(TraceRecord{Op: TraceSend}, buffer, err)
+	return bytesWritten, err
+}
+func (recorder *recorder) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
+	bytesRead, err = recorder.transport.ReceiveMessage(buffer, done)
+	recorder.tracer.write(TraceRecord{Op: TraceReceive}, buffer[:bytesRead], err)
+	return bytesRead, err
+}
+func (recorder *recorder) LockDevice() error {
+	if locker, ok := recorder.transport.(DeviceLocker); ok {
+		return locker.LockDevice()
+	}
+	return nil
+}
+func (recorder *recorder) UnlockDevice() {
+	if locker, ok := recorder.transport.(DeviceLocker); ok {
+		locker.UnlockDevice()
+	}
+}
+func (recorder *recorder) Close() {
+	recorder.transport.Close()
+	recorder.tracer.write(TraceRecord{Op: TraceClose}, nil, nil)
+}
+
+// Replay is a Transport that plays a trace back. Each request is answered with the response
+// recorded after the next matching request in the trace, wrapping around at its end, so a trace
+// answers any subset of the commands it recorded. Redacted requests match on their command.
+type Replay struct {
+	Records    []TraceRecord
+	next       int
+	bufferSize uint32
+}
+
+// LoadReplay reads the trace file at path
+func LoadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadReplay(file)
+}
+
+// ReadReplay reads a trace from reader
+func ReadReplay(reader io.Reader) (*Replay, error) {
+	replay := &Replay{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record := TraceRecord{}
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace record on line %d: %w", line, err)
+		}
+		_, err = hex.DecodeString(record.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace data on line %d", line)
+		}
+		replay.Records = append(replay.Records, record)
+	}
+	return replay, scanner.Err()
+}
+
+// find moves past the next record for op that matches, returning its data and recorded error
+func (replay *Replay) find(op string, matches func(record TraceRecord, data []byte) bool) ([]byte, bool, error) {
+	for i := 0; i < len(replay.Records); i++ {
+		index := (replay.next + i) % len(replay.Records)
+		record := replay.Records[index]
+		if record.Op != op {
+			continue
+		}
+		data, _ := hex.DecodeString(record.Data)
+		if matches != nil && !matches(record, data) {
+			continue
+		}
+		replay.next = index + 1
+		return data, true, record.err()
+	}
+	return nil, false, nil
+}
+
+func (replay *Replay) Init() error {
+	_, found, err := replay.find(TraceInit, nil)
+	if !found {
+		return errors.New("trace has no init record")
+	}
+	replay.bufferSize = 0
+	if err == nil {
+		replay.bufferSize = replay.Records[replay.next-1].BufferSize
+	}
+	return err
+}
+func (replay *Replay) GetBufferSize() uint32 {
+	return replay.bufferSize
+}
+func (replay *Replay) SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error) {
+	_, found, err := replay.find(TraceSend, func(record TraceRecord, data []byte) bool {
+		if record.Redacted && len(buffer) >= 8 && len(data) >= 8 {
+			return bytes.Equal(buffer[:8], data[:8])
+		}
+		return bytes.Equal(buffer, data)
+	})
+	if !found {
+		return 0, errors.New("trace has no record of the request")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint32(len(buffer)), nil
+}
+func (replay *Replay) ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error) {
+	if replay.next == 0 || replay.next >= len(replay.Records) || replay.Records[replay.next].Op != TraceReceive {
+		return 0, errors.New("trace has no response to the request")
+	}
+	record := replay.Records[replay.next]
+	replay.next++
+	err = record.err()
+	if err != nil {
+		return 0, err
+	}
+	data, _ := hex.DecodeString(record.Data)
+	return uint32(copy(buffer, data)), nil
+}
+func (replay *Replay) Close() {}
@@ -0,0 +1,96 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package heci
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Transport exchanges whole messages with an ME client. Heci is the Transport for the MEI device,
+// Fake answers from memory for tests.
+type Transport interface {
+	Init() error
+	GetBufferSize() uint32
+	SendMessage(buffer []byte, done *uint32) (bytesWritten uint32, err error)
+	ReceiveMessage(buffer []byte, done *uint32) (bytesRead uint32, err error)
+	Close()
+}
+
+var _ Transport = &Heci{}
+
+// NewTransport returns the Transport PTHI commands are sent over. It is a connection to the MEI
+// device shared through DefaultSession unless replaced, for example by the AMT emulator.
+var NewTransport = func() Transport {
+	return DefaultSession.Transport()
+}
+
+// ErrTimeout is returned by ReceiveMessage when the ME does not respond before the read deadline
+var ErrTimeout = errors.New("timed out waiting for the ME to respond")
+
+// ErrMEReset is returned by SendMessage and ReceiveMessage when the ME was reset during an exchange.
+// The client has been connected again, but the request was dropped and is not sent again, as only
+// the caller knows whether that is safe.
+var ErrMEReset = errors.New("the ME was reset and dropped the request")
+
+// resetError wraps the error that revealed an ME reset, so that it matches both ErrMEReset and its errno
+type resetError struct {
+	err error
+}
+
+func (e *resetError) Error() string {
+	return ErrMEReset.Error() + ": " + e.err.Error()
+}
+
+func (e *resetError) Unwrap() error {
+	return e.err
+}
+
+func (e *resetError) Is(target error) bool {
+	return target == ErrMEReset
+}
+
+// ReadTimeout is how long Heci waits for a response when its Timeout is not set
+var ReadTimeout = 30 * time.Second
+
+// LockTimeout is how long LockDevice waits for another process to release the MEI device
+var LockTimeout = 5 * time.Second
+
+// ErrDeviceBusy is returned by LockDevice when another process holds the MEI device for longer than LockTimeout
+var ErrDeviceBusy = errors.New("the MEI device is busy, held by another process")
+
+// ReadContext cancels the reads of every Heci whose Context is not set, rpc cancels it on interrupt
+var ReadContext = context.Background()
+
+// pollInterval bounds each wait for a response so that cancellation is noticed
+const pollInterval = 100 * time.Millisecond
+
+// readDeadline returns the context a read is cancelled by and the time it must complete by
+func readDeadline(ctx context.Context, timeout time.Duration) (context.Context, time.Time) {
+	if ctx == nil {
+		ctx = ReadContext
+	}
+	if timeout <= 0 {
+		timeout = ReadTimeout
+	}
+	return ctx, time.Now().Add(timeout)
+}
+
+// nextWait returns how long to wait before checking ctx and deadline again, or an error once
+// either has passed
+func nextWait(ctx context.Context, deadline time.Time) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 0, ErrTimeout
+	}
+	if remaining > pollInterval {
+		return pollInterval, nil
+	}
+	return remaining, nil
+}
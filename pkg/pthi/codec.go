@@ -0,0 +1,161 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"rpc/pkg/heci"
+)
+
+// RESPONSE_FLAG is set in the command code of a response on top of the code of the request it answers
+const RESPONSE_FLAG = 0x00800000
+
+const requestHeaderSize = 12
+const responseHeaderSize = 16
+
+// encodeRequest serialises a pointer to a request struct that starts with a MessageHeader.
+// The header length is derived from the size of the struct, so every field must be of fixed size.
+func encodeRequest(request interface{}) ([]byte, error) {
+	v := reflect.ValueOf(request)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct || v.Elem().NumField() == 0 {
+		return nil, errors.New("request must be a pointer to a struct")
+	}
+	header, ok := v.Elem().Field(0).Addr().Interface().(*MessageHeader)
+	if !ok {
+		return nil, errors.New("request must start with a MessageHeader")
+	}
+	size := binary.Size(request)
+	if size < 0 {
+		return nil, errors.New("request is not of fixed size")
+	}
+	header.Length = uint32(size - requestHeaderSize)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, request)
+	return buf.Bytes(), nil
+}
+
+// frameRequest prefixes a variable length payload with header, setting the header length to match
+func frameRequest(header MessageHeader, payload []byte) []byte {
+	header.Length = uint32(len(payload))
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// decodeResponse checks that the payload left in buf fills the fields of response after its
+// ResponseMessageHeader exactly and reads them, storing header in the response as well
+func decodeResponse(header ResponseMessageHeader, buf *bytes.Buffer, response interface{}) error {
+	v := reflect.ValueOf(response)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct || v.Elem().NumField() == 0 {
+		return errors.New("response must be a pointer to a struct")
+	}
+	responseHeader, ok := v.Elem().Field(0).Addr().Interface().(*ResponseMessageHeader)
+	if !ok {
+		return errors.New("response must start with a ResponseMessageHeader")
+	}
+	*responseHeader = header
+	size := binary.Size(response)
+	if size < 0 {
+		return errors.New("response is not of fixed size")
+	}
+	size -= responseHeaderSize
+	if buf.Len() < size {
+		return fmt.Errorf("response is truncated, expected %d bytes of payload but got %d", size, buf.Len())
+	}
+	if buf.Len() > size {
+		return fmt.Errorf("response length is invalid, expected %d bytes of payload but got %d", size, buf.Len())
+	}
+	for i := 1; i < v.Elem().NumField(); i++ {
+		binary.Read(buf, binary.LittleEndian, v.Elem().Field(i).Addr().Interface())
+	}
+	return nil
+}
+
+// readOnlyCommands only read state from AMT, so they are sent again when an ME reset drops them
+var readOnlyCommands = map[uint32]bool{
+	PROVISIONING_MODE_REQUEST:                   true,
+	PROVISIONING_STATE_REQUEST:                  true,
+	CODE_VERSIONS_REQUEST:                       true,
+	GET_MAC_ADDRESSES_REQUEST:                   true,
+	ENUMERATE_HASH_HANDLES_REQUEST:              true,
+	GET_RNG_SEED_STATUS_REQUEST:                 true,
+	GET_DNS_SUFFIX_LIST_REQUEST:                 true,
+	GET_REMOTE_ACCESS_CONNECTION_STATUS_REQUEST: true,
+	GET_CURRENT_POWER_POLICY_REQUEST:            true,
+	GET_LAN_INTERFACE_SETTINGS_REQUEST:          true,
+	GET_FEATURES_STATE_REQUEST:                  true,
+	GET_LAST_HOST_RESET_REASON_REQUEST:          true,
+	GET_ZERO_TOUCH_ENABLED_REQUEST:              true,
+	GET_PROVISIONING_TLS_MODE_REQUEST:           true,
+	GET_CERTHASH_ENTRY_REQUEST:                  true,
+	GET_PKI_FQDN_SUFFIX_REQUEST:                 true,
+	GET_FQDN_REQUEST:                            true,
+	GET_LOCAL_SYSTEM_ACCOUNT_REQUEST:            true,
+	GET_EHBC_STATE_REQUEST:                      true,
+	GET_CONTROL_MODE_REQUEST:                    true,
+	GET_UUID_REQUEST:                            true,
+}
+
+// transact sends an encoded request and validates the response header against it: the response
+// must answer the same command, carry as many bytes as its header says and report success.
+// The payload that follows the status is left in the returned buffer.
+func (pthi *PTHICommand) transact(request []byte) (ResponseMessageHeader, *bytes.Buffer, error) {
+	if len(request) < requestHeaderSize {
+		return ResponseMessageHeader{}, nil, errors.New("request header is truncated")
+	}
+	command := binary.LittleEndian.Uint32(request[4:8])
+	result, err := pthi.Call(request, uint32(len(request)))
+	if errors.Is(err, heci.ErrMEReset) && readOnlyCommands[command] {
+		// the ME dropped the request when it was reset, only a request that changes nothing is safe to send again
+		result, err = pthi.Call(request, uint32(len(request)))
+	}
+	if errors.Is(err, heci.ErrTimeout) {
+		return ResponseMessageHeader{}, nil, fmt.Errorf("no response to command 0x%08X: %w", command, err)
+	}
+	if err != nil {
+		return ResponseMessageHeader{}, nil, err
+	}
+	return validateResponse(command, result)
+}
+
+// validateResponse reads the response header from result and checks it answers command
+func validateResponse(command uint32, result []byte) (ResponseMessageHeader, *bytes.Buffer, error) {
+	if len(result) < responseHeaderSize {
+		return ResponseMessageHeader{}, nil, errors.New("response header is truncated")
+	}
+	buf := bytes.NewBuffer(result)
+	header := readHeaderResponse(buf)
+	if header.Header.Command.val != command|RESPONSE_FLAG {
+		return header, nil, fmt.Errorf("response command 0x%08X does not answer request 0x%08X", header.Header.Command.val, command)
+	}
+	if int(header.Header.Length) != len(result)-requestHeaderSize {
+		return header, nil, fmt.Errorf("response length %d does not match the %d bytes read", header.Header.Length, len(result)-requestHeaderSize)
+	}
+	return header, buf, statusError(header.Status)
+}
+
+// send encodes a fixed size request and returns the validated response header and payload
+func (pthi *PTHICommand) send(request interface{}) (ResponseMessageHeader, *bytes.Buffer, error) {
+	data, err := encodeRequest(request)
+	if err != nil {
+		return ResponseMessageHeader{}, nil, err
+	}
+	return pthi.transact(data)
+}
+
+// call sends a fixed size request and decodes the fixed size response into response.
+// A command whose request and response have no variable length fields needs nothing else.
+func (pthi *PTHICommand) call(request interface{}, response interface{}) error {
+	header, buf, err := pthi.send(request)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(header, buf, response)
+}
@@ -0,0 +1,123 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newResponse builds a response to request with the given status, followed by each payload value
+func newResponse(request uint32, status uint32, payload ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, value := range payload {
+		binary.Write(&body, binary.LittleEndian, value)
+	}
+	header := CreateRequestHeader(request | RESPONSE_FLAG)
+	header.Length = uint32(4 + body.Len())
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, header)
+	binary.Write(&bin_buf, binary.LittleEndian, status)
+	bin_buf.Write(body.Bytes())
+	return bin_buf.Bytes()
+}
+
+func TestEncodeRequestDerivesLength(t *testing.T) {
+	command := GetCertHashEntryRequest{
+		Header:     CreateRequestHeader(GET_CERTHASH_ENTRY_REQUEST),
+		HashHandle: 7,
+	}
+	result, err := encodeRequest(&command)
+	assert.NoError(t, err)
+	assert.Len(t, result, 16)
+	assert.Equal(t, uint32(4), command.Header.Length)
+	assert.Equal(t, uint32(GET_CERTHASH_ENTRY_REQUEST), binary.LittleEndian.Uint32(result[4:8]))
+	assert.Equal(t, uint32(4), binary.LittleEndian.Uint32(result[8:12]))
+	assert.Equal(t, uint32(7), binary.LittleEndian.Uint32(result[12:16]))
+}
+func TestEncodeRequestHeaderOnly(t *testing.T) {
+	command := GetUUIDRequest{
+		Header: CreateRequestHeader(GET_UUID_REQUEST),
+	}
+	result, err := encodeRequest(&command)
+	assert.NoError(t, err)
+	assert.Len(t, result, 12)
+	assert.Equal(t, uint32(0), command.Header.Length)
+}
+func TestEncodeRequestNotPointer(t *testing.T) {
+	_, err := encodeRequest(GetUUIDRequest{})
+	assert.Error(t, err)
+}
+func TestEncodeRequestWithoutHeader(t *testing.T) {
+	_, err := encodeRequest(&LastHostResetReason{})
+	assert.Error(t, err)
+}
+func TestEncodeRequestVariableSize(t *testing.T) {
+	_, err := encodeRequest(&struct {
+		Header MessageHeader
+		Suffix string
+	}{})
+	assert.Error(t, err)
+}
+
+func TestFrameRequest(t *testing.T) {
+	result := frameRequest(CreateRequestHeader(SET_DNS_SUFFIX_REQUEST), []byte{1, 2, 3})
+	assert.Len(t, result, 15)
+	assert.Equal(t, uint32(3), binary.LittleEndian.Uint32(result[8:12]))
+	assert.Equal(t, []byte{1, 2, 3}, result[12:])
+}
+
+func TestValidateResponse(t *testing.T) {
+	header, buf, err := validateResponse(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, []byte{2, 0, 0, 0}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8), header.Header.Length)
+	assert.Equal(t, []byte{2, 0, 0, 0}, buf.Bytes())
+}
+func TestValidateResponseWrongCommand(t *testing.T) {
+	_, _, err := validateResponse(GET_CONTROL_MODE_REQUEST, newResponse(GET_UUID_REQUEST, 0, []byte{2, 0, 0, 0}))
+	assert.EqualError(t, err, "response command 0x0480005C does not answer request 0x0400006B")
+}
+func TestValidateResponseLengthMismatch(t *testing.T) {
+	result := newResponse(GET_CONTROL_MODE_REQUEST, 0, []byte{2, 0, 0, 0})
+	_, _, err := validateResponse(GET_CONTROL_MODE_REQUEST, result[:18])
+	assert.EqualError(t, err, "response length 8 does not match the 6 bytes read")
+}
+func TestValidateResponseTruncatedHeader(t *testing.T) {
+	result := newResponse(GET_CONTROL_MODE_REQUEST, 0)
+	_, _, err := validateResponse(GET_CONTROL_MODE_REQUEST, result[:10])
+	assert.EqualError(t, err, "response header is truncated")
+}
+func TestValidateResponseStatus(t *testing.T) {
+	_, _, err := validateResponse(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, uint32(AMT_STATUS_NOT_READY)))
+	assert.Equal(t, AMT_STATUS_NOT_READY, err)
+}
+
+func TestDecodeResponse(t *testing.T) {
+	header, buf, err := validateResponse(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, []byte{2, 0, 0, 0}))
+	assert.NoError(t, err)
+	response := GetControlModeResponse{}
+	err = decodeResponse(header, buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), response.State)
+	assert.Equal(t, header, response.Header)
+}
+func TestDecodeResponseTruncated(t *testing.T) {
+	response := GetControlModeResponse{}
+	err := decodeResponse(ResponseMessageHeader{}, bytes.NewBuffer([]byte{2, 0}), &response)
+	assert.EqualError(t, err, "response is truncated, expected 4 bytes of payload but got 2")
+}
+func TestDecodeResponseTrailingBytes(t *testing.T) {
+	response := UnprovisionResponse{}
+	err := decodeResponse(ResponseMessageHeader{}, bytes.NewBuffer([]byte{2, 0, 0, 0}), &response)
+	assert.EqualError(t, err, "response length is invalid, expected 0 bytes of payload but got 4")
+}
+func TestDecodeResponseWithoutHeader(t *testing.T) {
+	response := LastHostResetReason{}
+	err := decodeResponse(ResponseMessageHeader{}, bytes.NewBuffer(nil), &response)
+	assert.Error(t, err)
+}
@@ -8,25 +8,46 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"net"
 	"rpc/pkg/heci"
 )
 
 type PTHICommand struct {
-	heci heci.Heci
+	heci    heci.Transport
+	initErr error
 }
 
+// NewPTHICommand opens the PTHI client over heci.NewTransport, the MEI device by default.
+// When the device cannot be opened, the error is returned by every command sent.
 func NewPTHICommand() PTHICommand {
-	heci := heci.Heci{}
+	return NewPTHICommandWithTransport(heci.NewTransport())
+}
 
-	heci.Init()
+// NewPTHICommandWithTransport opens the PTHI client over transport
+func NewPTHICommandWithTransport(transport heci.Transport) PTHICommand {
+	err := transport.Init()
 	return PTHICommand{
-		heci: heci,
+		heci:    transport,
+		initErr: err,
 	}
 }
 func (pthi *PTHICommand) Close() {
+	if pthi.initErr != nil {
+		return
+	}
 	pthi.heci.Close()
 }
 func (pthi *PTHICommand) Call(command []byte, commandSize uint32) (result []byte, err error) {
+	if pthi.initErr != nil {
+		return nil, pthi.initErr
+	}
+	if locker, ok := pthi.heci.(heci.DeviceLocker); ok {
+		err = locker.LockDevice()
+		if err != nil {
+			return nil, err
+		}
+		defer locker.UnlockDevice()
+	}
 	size := pthi.heci.GetBufferSize()
 
 	bytesWritten, err := pthi.heci.SendMessage(command, &commandSize)
@@ -44,7 +65,7 @@ func (pthi *PTHICommand) Call(command []byte, commandSize uint32) (result []byte
 	if bytesRead == 0 {
 		return nil, errors.New("empty response from AMT")
 	}
-	return readBuffer, nil
+	return readBuffer[:bytesRead], nil
 }
 func CreateRequestHeader(command uint32) MessageHeader {
 	return MessageHeader{
@@ -60,45 +81,512 @@ func CreateRequestHeader(command uint32) MessageHeader {
 	}
 }
 func (pthi *PTHICommand) GetUUID() (uuid string, err error) {
-	commandSize := (uint32)(12) //(uint32)(unsafe.Sizeof(GetUUIDRequest{}))
 	command := GetUUIDRequest{
 		Header: CreateRequestHeader(GET_UUID_REQUEST),
 	}
-	var bin_buf bytes.Buffer
-	binary.Write(&bin_buf, binary.LittleEndian, command)
-	result, err := pthi.Call(bin_buf.Bytes(), commandSize)
+	response := GetUUIDResponse{}
+	err = pthi.call(&command, &response)
 	if err != nil {
 		return "", err
 	}
-	buf2 := bytes.NewBuffer(result)
-	response := GetUUIDResponse{
-		Header: readHeaderResponse(buf2),
-	}
-
-	binary.Read(buf2, binary.LittleEndian, &response.UUID)
 
 	return string(([]byte)(response.UUID[:])), nil
 }
 
 func (pthi *PTHICommand) GetControlMode() (state int, err error) {
-	commandSize := (uint32)(12)
 	command := GetControlModeRequest{
 		Header: CreateRequestHeader(GET_CONTROL_MODE_REQUEST),
 	}
-	var bin_buf bytes.Buffer
-	binary.Write(&bin_buf, binary.LittleEndian, command)
-	result, err := pthi.Call(bin_buf.Bytes(), commandSize)
+	response := GetControlModeResponse{}
+	err = pthi.call(&command, &response)
 	if err != nil {
 		return -1, err
 	}
-	buf2 := bytes.NewBuffer(result)
-	response := GetControlModeResponse{
-		Header: readHeaderResponse(buf2),
+
+	return int(response.State), nil
+}
+
+// GetCodeVersions returns the BIOS version and every entry of the AMT version table
+func (pthi *PTHICommand) GetCodeVersions() (CodeVersions, error) {
+	command := GetCodeVersionsRequest{
+		Header: CreateRequestHeader(CODE_VERSIONS_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return CodeVersions{}, err
+	}
+	response := GetCodeVersionsResponse{
+		Header: header,
+	}
+
+	return decodeCodeVersions(buf2, &response)
+}
+
+// EnumerateHashHandles returns the handles of every certificate hash entry stored in AMT
+func (pthi *PTHICommand) EnumerateHashHandles() ([]uint32, error) {
+	command := EnumerateHashHandlesRequest{
+		Header: CreateRequestHeader(ENUMERATE_HASH_HANDLES_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return nil, err
+	}
+	response := GetHashHandlesResponse{
+		Header: header,
+	}
+
+	return decodeHashHandles(buf2, &response)
+}
+
+// GetCertHashEntry returns the certificate hash entry stored under hashHandle, including inactive entries
+func (pthi *PTHICommand) GetCertHashEntry(hashHandle uint32) (CertHashEntry, error) {
+	command := GetCertHashEntryRequest{
+		Header:     CreateRequestHeader(GET_CERTHASH_ENTRY_REQUEST),
+		HashHandle: hashHandle,
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return CertHashEntry{}, err
+	}
+	response := GetCertHashEntryResponse{
+		Header: header,
+	}
+
+	return decodeCertHashEntry(buf2, &response)
+}
+
+// GetDNSSuffix returns the PKI DNS suffix (as set in MEBX or by DHCP option 15) stored in AMT
+func (pthi *PTHICommand) GetDNSSuffix() (string, error) {
+	command := GetPKIFQDNSuffixRequest{
+		Header: CreateRequestHeader(GET_PKI_FQDN_SUFFIX_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return "", err
+	}
+	response := GetPKIFQDNSuffixResponse{
+		Header: header,
+	}
+
+	return decodePKIFQDNSuffix(buf2, &response)
+}
+
+// GetDNSSuffixList returns every DNS suffix AMT uses to decide whether it is inside the enterprise network
+func (pthi *PTHICommand) GetDNSSuffixList() ([]string, error) {
+	command := GetDNSSuffixListRequest{
+		Header: CreateRequestHeader(GET_DNS_SUFFIX_LIST_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return nil, err
+	}
+	response := GetDNSSuffixListResponse{
+		Header: header,
+	}
+
+	return decodeDNSSuffixList(buf2, &response)
+}
+
+// GetFQDN returns the host FQDN stored in AMT along with its shared and dynamic DNS settings
+func (pthi *PTHICommand) GetFQDN() (FQDN, error) {
+	command := GetFQDNRequest{
+		Header: CreateRequestHeader(GET_FQDN_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return FQDN{}, err
+	}
+	response := GetFQDNResponse{
+		Header: header,
+	}
+
+	return decodeFQDN(buf2, &response)
+}
+
+// GetRemoteAccessConnectionStatus returns the CIRA connection status and the MPS AMT is connected to
+func (pthi *PTHICommand) GetRemoteAccessConnectionStatus() (RemoteAccessStatus, error) {
+	command := GetRemoteAccessConnectionStatusRequest{
+		Header: CreateRequestHeader(GET_REMOTE_ACCESS_CONNECTION_STATUS_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return RemoteAccessStatus{}, err
+	}
+	response := GetRemoteAccessConnectionStatusResponse{
+		Header: header,
+	}
+
+	return decodeRemoteAccessConnectionStatus(buf2, &response)
+}
+
+// OpenUserInitiatedConnection asks AMT to open a CIRA connection to the configured MPS
+func (pthi *PTHICommand) OpenUserInitiatedConnection() error {
+	command := OpenUserInitiatedConnectionRequest{
+		Header: CreateRequestHeader(OPEN_USER_INITIATED_CONNECTION_REQUEST),
+	}
+	response := OpenUserInitiatedConnectionResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// CloseUserInitiatedConnection asks AMT to close a CIRA connection opened by OpenUserInitiatedConnection
+func (pthi *PTHICommand) CloseUserInitiatedConnection() error {
+	command := CloseUserInitiatedConnectionRequest{
+		Header: CreateRequestHeader(CLOSE_USER_INITIATED_CONNECTION_REQUEST),
+	}
+	response := CloseUserInitiatedConnectionResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// GetLANInterfaceSettings returns the settings of the wired or, when useWireless is set, the wireless interface
+func (pthi *PTHICommand) GetLANInterfaceSettings(useWireless bool) (InterfaceSettings, error) {
+	command := GetLANInterfaceSettingsRequest{
+		Header:         CreateRequestHeader(GET_LAN_INTERFACE_SETTINGS_REQUEST),
+		InterfaceIndex: 0,
+	}
+	if useWireless {
+		command.InterfaceIndex = 1
+	}
+	response := GetLANInterfaceSettingsResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return InterfaceSettings{}, err
+	}
+
+	address := response.Ipv4Address
+	return InterfaceSettings{
+		IsEnabled:   response.Enabled == 1,
+		IPAddress:   net.IPv4(byte(address>>24), byte(address>>16), byte(address>>8), byte(address)),
+		DHCPEnabled: response.DhcpEnabled == 1,
+		DHCPMode:    response.DhcpIpMode,
+		LinkStatus:  response.LinkStatus,
+		MACAddress:  net.HardwareAddr(append([]byte{}, response.MacAddress[:]...)),
+	}, nil
+}
+
+// GetLocalSystemAccount returns the local system account used for host based activation.
+// The response buffer is wiped before returning, the returned copy is left for the caller to Wipe.
+func (pthi *PTHICommand) GetLocalSystemAccount() (LocalSystemAccount, error) {
+	command := GetLocalSystemAccountRequest{
+		Header: CreateRequestHeader(GET_LOCAL_SYSTEM_ACCOUNT_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return LocalSystemAccount{}, err
+	}
+	defer wipe(buf2.Bytes())
+	response := GetLocalSystemAccountResponse{}
+	defer response.Account.Wipe()
+	err = decodeResponse(header, buf2, &response)
+	if err != nil {
+		return LocalSystemAccount{}, err
+	}
+
+	return response.Account, nil
+}
+
+// GetProvisioningState returns whether AMT is in pre, in or post provisioning state
+func (pthi *PTHICommand) GetProvisioningState() (uint32, error) {
+	command := GetProvisioningStateRequest{
+		Header: CreateRequestHeader(PROVISIONING_STATE_REQUEST),
+	}
+	response := GetProvisioningStateResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.ProvisioningState, nil
+}
+
+// GetProvisioningMode returns the mode AMT was provisioned in
+func (pthi *PTHICommand) GetProvisioningMode() (ProvisioningMode, error) {
+	command := GetProvisioningModeRequest{
+		Header: CreateRequestHeader(PROVISIONING_MODE_REQUEST),
+	}
+	response := GetProvisioningModeResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return ProvisioningMode{}, err
+	}
+
+	return ProvisioningMode{
+		Mode:       response.ProvisioningMode,
+		LegacyMode: response.LegacyMode == 1,
+	}, nil
+}
+
+// GetProvisioningTLSMode returns whether remote configuration uses PKI or PSK
+func (pthi *PTHICommand) GetProvisioningTLSMode() (uint32, error) {
+	command := GetProvisioningTLSModeRequest{
+		Header: CreateRequestHeader(GET_PROVISIONING_TLS_MODE_REQUEST),
+	}
+	response := GetProvisioningTLSModeResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.ProvisioningTLSMode, nil
+}
+
+// GetZeroTouchEnabled returns whether zero touch remote configuration is enabled
+func (pthi *PTHICommand) GetZeroTouchEnabled() (bool, error) {
+	command := GetZeroTouchEnabledRequest{
+		Header: CreateRequestHeader(GET_ZERO_TOUCH_ENABLED_REQUEST),
+	}
+	response := GetZeroTouchEnabledResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return false, err
+	}
+
+	return response.ZeroTouchEnabled == 1, nil
+}
+
+// GetEHBCState returns whether embedded host based configuration is enabled.
+// Firmware older than 8.1.20 does not support the command and returns an error status.
+func (pthi *PTHICommand) GetEHBCState() (bool, error) {
+	command := GetEHBCStateRequest{
+		Header: CreateRequestHeader(GET_EHBC_STATE_REQUEST),
+	}
+	response := GetEHBCStateResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return false, err
+	}
+
+	return response.EHBCState == 1, nil
+}
+
+// GetFeaturesState returns whether SOL and IDER sessions are open, system defense is activated and the web UI is enabled
+func (pthi *PTHICommand) GetFeaturesState() (FeaturesState, error) {
+	redirection, err := pthi.getFeatureState(REDIRECTION_SESSION)
+	if err != nil {
+		return FeaturesState{}, err
+	}
+	systemDefense, err := pthi.getFeatureState(SYSTEM_DEFENSE)
+	if err != nil {
+		return FeaturesState{}, err
+	}
+	webUI, err := pthi.getFeatureState(WEB_UI)
+	if err != nil {
+		return FeaturesState{}, err
+	}
+	return FeaturesState{
+		IDEROpen:               redirection[0] == 1,
+		SOLOpen:                redirection[1] == 1,
+		SystemDefenseActivated: systemDefense[0] == 1,
+		WebUIEnabled:           webUI[0] == 1,
+	}, nil
+}
+
+func (pthi *PTHICommand) getFeatureState(requestID uint32) ([3]uint32, error) {
+	command := GetFeaturesStateRequest{
+		Header:    CreateRequestHeader(GET_FEATURES_STATE_REQUEST),
+		RequestID: requestID,
+	}
+	response := GetFeaturesStateResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return [3]uint32{}, err
+	}
+	if response.RequestID != requestID {
+		return [3]uint32{}, errors.New("features state response does not match request")
 	}
 
-	binary.Read(buf2, binary.LittleEndian, &response.State)
+	return response.Data, nil
+}
 
-	return response.State, nil
+// GetLastHostResetReason returns whether the host was last reset remotely through AMT or by other means
+func (pthi *PTHICommand) GetLastHostResetReason() (LastHostResetReason, error) {
+	command := GetLastHostResetReasonRequest{
+		Header: CreateRequestHeader(GET_LAST_HOST_RESET_REASON_REQUEST),
+	}
+	response := GetLastHostResetReasonResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return LastHostResetReason{}, err
+	}
+
+	return LastHostResetReason{
+		Reason:                 response.Reason,
+		RemoteControlTimeStamp: response.RemoteControlTimeStamp,
+	}, nil
+}
+
+// GetCurrentPowerPolicy returns the name of the active power package
+func (pthi *PTHICommand) GetCurrentPowerPolicy() (string, error) {
+	command := GetCurrentPowerPolicyRequest{
+		Header: CreateRequestHeader(GET_CURRENT_POWER_POLICY_REQUEST),
+	}
+	header, buf2, err := pthi.send(&command)
+	if err != nil {
+		return "", err
+	}
+	response := GetCurrentPowerPolicyResponse{
+		Header: header,
+	}
+
+	return decodeCurrentPowerPolicy(buf2, &response)
+}
+
+// SetDNSSuffix sets the PKI DNS suffix used to match the provisioning certificate.
+// AMT only accepts it while in pre-provisioning state.
+func (pthi *PTHICommand) SetDNSSuffix(suffix string) error {
+	var payload bytes.Buffer
+	err := writeAMTANSIString(&payload, suffix, FQDN_MAX_SIZE)
+	if err != nil {
+		return err
+	}
+	header, buf2, err := pthi.transact(frameRequest(CreateRequestHeader(SET_DNS_SUFFIX_REQUEST), payload.Bytes()))
+	if err != nil {
+		return err
+	}
+	response := SetDNSSuffixResponse{}
+
+	return decodeResponse(header, buf2, &response)
+}
+
+// SetHostFQDN sets the FQDN of the host that AMT reports and, when shared, uses for itself
+func (pthi *PTHICommand) SetHostFQDN(fqdn string) error {
+	if len(fqdn) > FQDN_MAX_SIZE {
+		return errors.New("fqdn length exceeds maximum")
+	}
+	command := SetHostFQDNRequest{
+		Header:     CreateRequestHeader(SET_HOST_FQDN_REQUEST),
+		FQDNLength: uint16(len(fqdn)),
+	}
+	copy(command.FQDN[:], fqdn)
+	request, err := encodeRequest(&command)
+	if err != nil {
+		return err
+	}
+	// the padding after the FQDN is sent but not counted, as the C client does
+	binary.LittleEndian.PutUint32(request[8:12], uint32(2+len(fqdn)))
+	header, buf2, err := pthi.transact(request)
+	if err != nil {
+		return err
+	}
+	response := SetHostFQDNResponse{}
+
+	return decodeResponse(header, buf2, &response)
+}
+
+// GetMACAddresses returns the MAC address dedicated to AMT and the MAC address of the host interface
+func (pthi *PTHICommand) GetMACAddresses() (MACAddresses, error) {
+	command := GetMACAddressesRequest{
+		Header: CreateRequestHeader(GET_MAC_ADDRESSES_REQUEST),
+	}
+	response := GetMACAddressesResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return MACAddresses{}, err
+	}
+
+	return MACAddresses{
+		DedicatedMAC: net.HardwareAddr(append([]byte{}, response.DedicatedMac[:]...)),
+		HostMAC:      net.HardwareAddr(append([]byte{}, response.HostMac[:]...)),
+	}, nil
+}
+
+// StartConfiguration moves AMT into in-provisioning state, where it waits for a provisioning server
+func (pthi *PTHICommand) StartConfiguration() error {
+	command := StartConfigurationRequest{
+		Header: CreateRequestHeader(START_CONFIGURATION_REQUEST),
+	}
+	response := StartConfigurationResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// StopConfiguration returns AMT from in-provisioning to pre-provisioning state
+func (pthi *PTHICommand) StopConfiguration() error {
+	command := StopConfigurationRequest{
+		Header: CreateRequestHeader(STOP_CONFIGURATION_REQUEST),
+	}
+	response := StopConfigurationResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// SetProvisioningServerOTP sets the one time password a provisioning server must present.
+// The request buffer is wiped once it has been sent.
+func (pthi *PTHICommand) SetProvisioningServerOTP(otp string) error {
+	var payload bytes.Buffer
+	defer func() { wipe(payload.Bytes()) }()
+	err := writeAMTANSIString(&payload, otp, PROVISIONING_OTP_MAX_LENGTH)
+	if err != nil {
+		return err
+	}
+	request := frameRequest(CreateRequestHeader(SET_PROVISIONING_SERVER_OTP_REQUEST), payload.Bytes())
+	defer wipe(request)
+	header, buf2, err := pthi.transact(request)
+	if err != nil {
+		return err
+	}
+	response := SetProvisioningServerOTPResponse{}
+
+	return decodeResponse(header, buf2, &response)
+}
+
+// GenerateRNGSeed asks AMT to generate the RNG seed needed for TLS
+func (pthi *PTHICommand) GenerateRNGSeed() error {
+	command := GenerateRNGSeedRequest{
+		Header: CreateRequestHeader(GENERATE_RNG_SEED_REQUEST),
+	}
+	response := GenerateRNGSeedResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// GetRNGSeedStatus returns whether the RNG seed exists, is being generated or does not exist
+func (pthi *PTHICommand) GetRNGSeedStatus() (uint32, error) {
+	command := GetRNGSeedStatusRequest{
+		Header: CreateRequestHeader(GET_RNG_SEED_STATUS_REQUEST),
+	}
+	response := GetRNGSeedStatusResponse{}
+	err := pthi.call(&command, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.RNGStatus, nil
+}
+
+// SetEnterpriseAccess tells AMT whether the host at hostIP has access to the enterprise network
+func (pthi *PTHICommand) SetEnterpriseAccess(hostIP net.IP, enterpriseAccess bool) error {
+	command := SetEnterpriseAccessRequest{
+		Header: CreateRequestHeader(SET_ENTERPRISE_ACCESS_REQUEST),
+	}
+	err := encodeEnterpriseAccess(&command, hostIP, enterpriseAccess)
+	if err != nil {
+		return err
+	}
+	response := SetEnterpriseAccessResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// Unprovision returns AMT to pre-provisioning state. Only devices activated in client control mode
+// can be unprovisioned this way, AMT rejects the request in admin control mode.
+func (pthi *PTHICommand) Unprovision() error {
+	command := UnprovisionRequest{
+		Header: CreateRequestHeader(UNPROVISION_REQUEST),
+		Mode:   CFG_PROVISIONING_MODE_NONE,
+	}
+	response := UnprovisionResponse{}
+
+	return pthi.call(&command, &response)
+}
+
+// statusError converts a non-success AMT status into an AMTStatus error
+func statusError(status uint32) error {
+	if AMTStatus(status) == AMT_STATUS_SUCCESS {
+		return nil
+	}
+	return AMTStatus(status)
 }
 
 func readHeaderResponse(header *bytes.Buffer) ResponseMessageHeader {
@@ -109,7 +597,6 @@ func readHeaderResponse(header *bytes.Buffer) ResponseMessageHeader {
 	binary.Read(header, binary.LittleEndian, &response.Header.Version.MinorNumber)
 	binary.Read(header, binary.LittleEndian, &response.Header.Reserved)
 	binary.Read(header, binary.LittleEndian, &response.Header.Command.val)
-	// binary.Read(header, binary.LittleEndian, &response.Header.Header.Command.fields)
 	binary.Read(header, binary.LittleEndian, &response.Header.Length)
 	binary.Read(header, binary.LittleEndian, &response.Status)
 	return response
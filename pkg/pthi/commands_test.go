@@ -8,13 +8,14 @@
 package pthi
 
 import (
+	"rpc/pkg/heci"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetGUID(t *testing.T) {
-	pthi := PTHICommand{}
+	pthi := PTHICommand{heci: &heci.Heci{}}
 	err := pthi.heci.Init()
 	defer pthi.Close()
 	assert.NoError(t, err)
@@ -24,3 +25,133 @@ func TestGetGUID(t *testing.T) {
 	assert.NotEmpty(t, result)
 
 }
+
+func TestGetCodeVersions(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetCodeVersions()
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.Versions)
+}
+
+func TestGetCertHashEntries(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	handles, err := pthi.EnumerateHashHandles()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, handles)
+
+	for _, handle := range handles {
+		result, err := pthi.GetCertHashEntry(handle)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, result.Name)
+	}
+}
+
+func TestGetDNSSuffix(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	_, err = pthi.GetDNSSuffix()
+	assert.NoError(t, err)
+	_, err = pthi.GetDNSSuffixList()
+	assert.NoError(t, err)
+	_, err = pthi.GetFQDN()
+	assert.NoError(t, err)
+}
+
+func TestGetRemoteAccessConnectionStatus(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	_, err = pthi.GetRemoteAccessConnectionStatus()
+	assert.NoError(t, err)
+}
+
+func TestGetLANInterfaceSettings(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetLANInterfaceSettings(false)
+	assert.NoError(t, err)
+	assert.Len(t, result.MACAddress, 6)
+}
+
+func TestGetLocalSystemAccount(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetLocalSystemAccount()
+	defer result.Wipe()
+	assert.NoError(t, err)
+	username, _ := result.Credentials()
+	assert.NotEmpty(t, username)
+}
+
+func TestGetProvisioningState(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetProvisioningState()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, result, uint32(2))
+}
+
+func TestGetProvisioningMode(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetProvisioningMode()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, result.Mode, uint32(3))
+}
+
+func TestGetFeaturesState(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	_, err = pthi.GetFeaturesState()
+	assert.NoError(t, err)
+}
+
+func TestGetCurrentPowerPolicy(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetCurrentPowerPolicy()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result)
+}
+
+func TestGetMACAddresses(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetMACAddresses()
+	assert.NoError(t, err)
+	assert.Len(t, result.HostMAC, 6)
+}
+
+func TestGetRNGSeedStatus(t *testing.T) {
+	pthi := PTHICommand{heci: &heci.Heci{}}
+	err := pthi.heci.Init()
+	defer pthi.Close()
+	assert.NoError(t, err)
+	result, err := pthi.GetRNGSeedStatus()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, result, uint32(RNG_STATUS_NOT_EXIST))
+}
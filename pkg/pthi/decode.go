@@ -0,0 +1,279 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// readAMTUnicodeString converts a fixed size AMT_UNICODE_STRING into a go string
+func readAMTUnicodeString(value AMTUnicodeString) (string, error) {
+	if int(value.Length) > UNICODE_STRING_LEN {
+		return "", errors.New("unicode string length exceeds buffer")
+	}
+	return strings.TrimRight(string(value.String[:value.Length]), "\u0000"), nil
+}
+
+// decodeCodeVersions reads the CODE_VERSIONS payload that follows the response header.
+// AMT only sends VersionsCount entries, so the table is read entry by entry.
+func decodeCodeVersions(buf *bytes.Buffer, response *GetCodeVersionsResponse) (CodeVersions, error) {
+	codeVersions := CodeVersions{}
+	err := binary.Read(buf, binary.LittleEndian, &response.BiosVersion)
+	if err != nil {
+		return codeVersions, errors.New("code versions response is truncated")
+	}
+	err = binary.Read(buf, binary.LittleEndian, &response.VersionsCount)
+	if err != nil {
+		return codeVersions, errors.New("code versions response is truncated")
+	}
+	if response.VersionsCount > VERSIONS_NUMBER {
+		return codeVersions, errors.New("code versions count exceeds maximum")
+	}
+	codeVersions.BiosVersion = strings.TrimRight(string(response.BiosVersion[:]), "\u0000")
+	for i := 0; i < int(response.VersionsCount); i++ {
+		err = binary.Read(buf, binary.LittleEndian, &response.Versions[i])
+		if err != nil {
+			return codeVersions, errors.New("code versions response is truncated")
+		}
+		description, err := readAMTUnicodeString(response.Versions[i].Description)
+		if err != nil {
+			return codeVersions, err
+		}
+		version, err := readAMTUnicodeString(response.Versions[i].Version)
+		if err != nil {
+			return codeVersions, err
+		}
+		codeVersions.Versions = append(codeVersions.Versions, AMTVersion{
+			Description: description,
+			Version:     version,
+		})
+	}
+	return codeVersions, nil
+}
+
+// readAMTANSIString reads an AMT_ANSI_STRING (a 16 bit length followed by that many characters).
+// The length is checked against the bytes remaining in buf and, when maxLength is non-zero, against maxLength.
+func readAMTANSIString(buf *bytes.Buffer, maxLength int) (string, error) {
+	var length uint16
+	err := binary.Read(buf, binary.LittleEndian, &length)
+	if err != nil {
+		return "", errors.New("ansi string length is truncated")
+	}
+	if maxLength > 0 && int(length) > maxLength {
+		return "", errors.New("ansi string length exceeds maximum")
+	}
+	if int(length) > buf.Len() {
+		return "", errors.New("ansi string length exceeds response")
+	}
+	return strings.TrimRight(string(buf.Next(int(length))), "\u0000"), nil
+}
+
+// writeAMTANSIString writes value as an AMT_ANSI_STRING, a uint16 length followed by the characters
+func writeAMTANSIString(buf *bytes.Buffer, value string, maxLength int) error {
+	if len(value) > maxLength {
+		return errors.New("ansi string length exceeds maximum")
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(len(value)))
+	buf.WriteString(value)
+	return nil
+}
+
+// decodeHashHandles reads the AMT_HASH_HANDLES payload that follows the response header
+func decodeHashHandles(buf *bytes.Buffer, response *GetHashHandlesResponse) ([]uint32, error) {
+	err := binary.Read(buf, binary.LittleEndian, &response.HashHandles.Length)
+	if err != nil {
+		return nil, errors.New("hash handles response is truncated")
+	}
+	if response.HashHandles.Length > CERT_HASH_MAX_NUMBER {
+		return nil, errors.New("hash handle count exceeds maximum")
+	}
+	if response.Header.Header.Length != 4+4+4*response.HashHandles.Length {
+		return nil, errors.New("hash handles response length does not match handle count")
+	}
+	handles := response.HashHandles.Handles[:response.HashHandles.Length]
+	err = binary.Read(buf, binary.LittleEndian, handles)
+	if err != nil {
+		return nil, errors.New("hash handles response is truncated")
+	}
+	return append([]uint32{}, handles...), nil
+}
+
+// decodeCertHashEntry reads the CERTHASH_ENTRY payload that follows the response header
+func decodeCertHashEntry(buf *bytes.Buffer, response *GetCertHashEntryResponse) (CertHashEntry, error) {
+	remaining := uint32(buf.Len())
+	for _, field := range []interface{}{&response.Hash.IsDefault, &response.Hash.IsActive, &response.Hash.CertificateHash, &response.Hash.HashAlgorithm} {
+		err := binary.Read(buf, binary.LittleEndian, field)
+		if err != nil {
+			return CertHashEntry{}, errors.New("certificate hash entry response is truncated")
+		}
+	}
+	name, err := readAMTANSIString(buf, 0)
+	if err != nil {
+		return CertHashEntry{}, err
+	}
+	if response.Header.Header.Length != 4+remaining-uint32(buf.Len()) {
+		return CertHashEntry{}, errors.New("certificate hash entry response length does not match name length")
+	}
+	response.Hash.Name = name
+	return response.Hash, nil
+}
+
+// decodePKIFQDNSuffix reads the AMT_ANSI_STRING suffix that follows the response header
+func decodePKIFQDNSuffix(buf *bytes.Buffer, response *GetPKIFQDNSuffixResponse) (string, error) {
+	remaining := uint32(buf.Len())
+	suffix, err := readAMTANSIString(buf, FQDN_MAX_SIZE)
+	if err != nil {
+		return "", err
+	}
+	if response.Header.Header.Length != 4+remaining-uint32(buf.Len()) {
+		return "", errors.New("dns suffix response length does not match suffix length")
+	}
+	response.Suffix = suffix
+	return response.Suffix, nil
+}
+
+// decodeDNSSuffixList reads the null separated suffix list that follows the response header
+func decodeDNSSuffixList(buf *bytes.Buffer, response *GetDNSSuffixListResponse) ([]string, error) {
+	err := binary.Read(buf, binary.LittleEndian, &response.DataLength)
+	if err != nil {
+		return nil, errors.New("dns suffix list response is truncated")
+	}
+	if response.Header.Header.Length != 4+2+uint32(response.DataLength) || int(response.DataLength) > buf.Len() {
+		return nil, errors.New("dns suffix list response length does not match data length")
+	}
+	response.Data = []string{}
+	for _, suffix := range strings.Split(string(buf.Next(int(response.DataLength))), "\u0000") {
+		if suffix != "" {
+			response.Data = append(response.Data, suffix)
+		}
+	}
+	return response.Data, nil
+}
+
+// decodeFQDN reads the FQDN settings that follow the response header. The FQDN is not an
+// AMT_ANSI_STRING, its characters follow HostNameLength directly.
+func decodeFQDN(buf *bytes.Buffer, response *GetFQDNResponse) (FQDN, error) {
+	for _, field := range []interface{}{&response.SharedFQDN, &response.DDNSUpdateEnabled, &response.DDNSPeriodicUpdateInterval, &response.DDNSTTL, &response.HostNameLength} {
+		err := binary.Read(buf, binary.LittleEndian, field)
+		if err != nil {
+			return FQDN{}, errors.New("fqdn response is truncated")
+		}
+	}
+	if response.HostNameLength > FQDN_MAX_SIZE {
+		return FQDN{}, errors.New("fqdn length exceeds maximum")
+	}
+	if int(response.HostNameLength) > buf.Len() {
+		return FQDN{}, errors.New("fqdn length exceeds response")
+	}
+	if response.Header.Header.Length != 4+4+4+4+4+4+response.HostNameLength {
+		return FQDN{}, errors.New("fqdn response length does not match fqdn length")
+	}
+	response.FQDN = strings.TrimRight(string(buf.Next(int(response.HostNameLength))), "\u0000")
+	return FQDN{
+		FQDN:                       response.FQDN,
+		SharedFQDN:                 response.SharedFQDN == 1,
+		DDNSUpdateEnabled:          response.DDNSUpdateEnabled == 1,
+		DDNSPeriodicUpdateInterval: response.DDNSPeriodicUpdateInterval,
+		DDNSTTL:                    response.DDNSTTL,
+		HostNameLength:             response.HostNameLength,
+	}, nil
+}
+
+// decodeRemoteAccessConnectionStatus reads the CIRA status that follows the response header
+func decodeRemoteAccessConnectionStatus(buf *bytes.Buffer, response *GetRemoteAccessConnectionStatusResponse) (RemoteAccessStatus, error) {
+	remaining := uint32(buf.Len())
+	for _, field := range []interface{}{&response.NetworkConnectionStatus, &response.RemoteAccessConnectionStatus, &response.RemoteAccessConnectionTrigger} {
+		err := binary.Read(buf, binary.LittleEndian, field)
+		if err != nil {
+			return RemoteAccessStatus{}, errors.New("remote access connection status response is truncated")
+		}
+	}
+	hostname, err := readAMTANSIString(buf, MPS_HOSTNAME_LENGTH)
+	if err != nil {
+		return RemoteAccessStatus{}, err
+	}
+	if response.Header.Header.Length != 4+remaining-uint32(buf.Len()) {
+		return RemoteAccessStatus{}, errors.New("remote access connection status response length does not match hostname length")
+	}
+	response.MPSHostname = hostname
+	return RemoteAccessStatus{
+		NetworkConnectionStatus:       response.NetworkConnectionStatus,
+		RemoteAccessConnectionStatus:  response.RemoteAccessConnectionStatus,
+		RemoteAccessConnectionTrigger: response.RemoteAccessConnectionTrigger,
+		MPSHostname:                   response.MPSHostname,
+	}, nil
+}
+
+// decodeCurrentPowerPolicy reads the AMT_ANSI_STRING policy name that follows the response header
+func decodeCurrentPowerPolicy(buf *bytes.Buffer, response *GetCurrentPowerPolicyResponse) (string, error) {
+	remaining := uint32(buf.Len())
+	policyName, err := readAMTANSIString(buf, 0)
+	if err != nil {
+		return "", err
+	}
+	if response.Header.Header.Length != 4+remaining-uint32(buf.Len()) {
+		return "", errors.New("power policy response length does not match policy name length")
+	}
+	response.PolicyName = policyName
+	return response.PolicyName, nil
+}
+
+// encodeEnterpriseAccess fills in the flags and host address of a SetEnterpriseAccessRequest
+func encodeEnterpriseAccess(command *SetEnterpriseAccessRequest, hostIP net.IP, enterpriseAccess bool) error {
+	if ipv4 := hostIP.To4(); ipv4 != nil {
+		copy(command.HostIPAddress[:], ipv4)
+	} else if ipv6 := hostIP.To16(); ipv6 != nil {
+		command.Flags = 1
+		copy(command.HostIPAddress[:], ipv6)
+	} else {
+		return errors.New("host ip address is invalid")
+	}
+	if enterpriseAccess {
+		command.EnterpriseAccess = 1
+	}
+	return nil
+}
+
+// wipe zeroes a buffer that held credentials
+func wipe(buffer []byte) {
+	buffer = buffer[:cap(buffer)]
+	for i := range buffer {
+		buffer[i] = 0
+	}
+}
+
+// Wipe zeroes the username and password
+func (account *LocalSystemAccount) Wipe() {
+	wipe(account.Username[:])
+	wipe(account.Password[:])
+}
+
+// Credentials returns the username and password as go strings
+func (account LocalSystemAccount) Credentials() (username string, password string) {
+	return readCString(account.Username[:]), readCString(account.Password[:])
+}
+
+// String keeps the password out of logs and formatted output
+func (account LocalSystemAccount) String() string {
+	return "{" + readCString(account.Username[:]) + " ********}"
+}
+
+// GoString keeps the password out of %#v output
+func (account LocalSystemAccount) GoString() string {
+	return account.String()
+}
+
+// readCString returns the characters of a null terminated string
+func readCString(value []uint8) string {
+	end := bytes.IndexByte(value, 0)
+	if end < 0 {
+		end = len(value)
+	}
+	return string(value[:end])
+}
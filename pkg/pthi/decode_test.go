@@ -0,0 +1,341 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUnicodeString(value string) AMTUnicodeString {
+	result := AMTUnicodeString{Length: uint16(len(value))}
+	copy(result.String[:], value)
+	return result
+}
+
+func TestReadAMTUnicodeString(t *testing.T) {
+	result, err := readAMTUnicodeString(newUnicodeString("Flash"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Flash", result)
+}
+func TestReadAMTUnicodeStringTooLong(t *testing.T) {
+	value := newUnicodeString("Flash")
+	value.Length = UNICODE_STRING_LEN + 1
+	_, err := readAMTUnicodeString(value)
+	assert.Error(t, err)
+}
+
+func TestDecodeCodeVersions(t *testing.T) {
+	var bin_buf bytes.Buffer
+	bios := [BIOS_VERSION_LEN]uint8{}
+	copy(bios[:], "BIOS.1.0")
+	binary.Write(&bin_buf, binary.LittleEndian, bios)
+	binary.Write(&bin_buf, binary.LittleEndian, uint32(2))
+	binary.Write(&bin_buf, binary.LittleEndian, AMTVersionType{Description: newUnicodeString("AMT"), Version: newUnicodeString("15.0.10")})
+	binary.Write(&bin_buf, binary.LittleEndian, AMTVersionType{Description: newUnicodeString("Netstack"), Version: newUnicodeString("15.0.10")})
+
+	result, err := decodeCodeVersions(&bin_buf, &GetCodeVersionsResponse{})
+	assert.NoError(t, err)
+	assert.Equal(t, "BIOS.1.0", result.BiosVersion)
+	assert.Equal(t, []AMTVersion{{"AMT", "15.0.10"}, {"Netstack", "15.0.10"}}, result.Versions)
+}
+func TestDecodeCodeVersionsTruncated(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, [BIOS_VERSION_LEN]uint8{})
+	binary.Write(&bin_buf, binary.LittleEndian, uint32(2))
+	binary.Write(&bin_buf, binary.LittleEndian, AMTVersionType{Description: newUnicodeString("AMT"), Version: newUnicodeString("15.0.10")})
+
+	_, err := decodeCodeVersions(&bin_buf, &GetCodeVersionsResponse{})
+	assert.Error(t, err)
+}
+func TestDecodeCodeVersionsCountTooLarge(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, [BIOS_VERSION_LEN]uint8{})
+	binary.Write(&bin_buf, binary.LittleEndian, uint32(VERSIONS_NUMBER+1))
+
+	_, err := decodeCodeVersions(&bin_buf, &GetCodeVersionsResponse{})
+	assert.Error(t, err)
+}
+
+func TestReadAMTANSIString(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(11))
+	bin_buf.WriteString("vprodemo.com")
+	result, err := readAMTANSIString(&bin_buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "vprodemo.co", result)
+	assert.Equal(t, 1, bin_buf.Len())
+}
+func TestReadAMTANSIStringExceedsResponse(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(1024))
+	bin_buf.WriteString("vprodemo.com")
+	_, err := readAMTANSIString(&bin_buf, 0)
+	assert.Error(t, err)
+}
+func TestReadAMTANSIStringExceedsMaximum(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(12))
+	bin_buf.WriteString("vprodemo.com")
+	_, err := readAMTANSIString(&bin_buf, 8)
+	assert.Error(t, err)
+}
+
+func TestDecodeHashHandles(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{3, 1, 2, 5})
+	response := GetHashHandlesResponse{}
+	response.Header.Header.Length = 4 + 4 + 4*3
+	result, err := decodeHashHandles(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 5}, result)
+}
+func TestDecodeHashHandlesLengthMismatch(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{3, 1, 2, 5})
+	response := GetHashHandlesResponse{}
+	response.Header.Header.Length = 4 + 4 + 4*2
+	_, err := decodeHashHandles(&bin_buf, &response)
+	assert.Error(t, err)
+}
+func TestDecodeHashHandlesTooMany(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint32(CERT_HASH_MAX_NUMBER+1))
+	response := GetHashHandlesResponse{}
+	_, err := decodeHashHandles(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func writeCertHashEntry(bin_buf *bytes.Buffer, name string, nameLength uint16) {
+	binary.Write(bin_buf, binary.LittleEndian, uint32(1))
+	binary.Write(bin_buf, binary.LittleEndian, uint32(0))
+	hash := [CERT_HASH_MAX_LENGTH]uint8{0xab, 0xcd}
+	binary.Write(bin_buf, binary.LittleEndian, hash)
+	binary.Write(bin_buf, binary.LittleEndian, uint8(2))
+	binary.Write(bin_buf, binary.LittleEndian, nameLength)
+	bin_buf.WriteString(name)
+}
+func TestDecodeCertHashEntry(t *testing.T) {
+	var bin_buf bytes.Buffer
+	writeCertHashEntry(&bin_buf, "VeriSign Class 3", 16)
+	response := GetCertHashEntryResponse{}
+	response.Header.Header.Length = 4 + 4 + 4 + CERT_HASH_MAX_LENGTH + 1 + 2 + 16
+	result, err := decodeCertHashEntry(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "VeriSign Class 3", result.Name)
+	assert.Equal(t, uint32(1), result.IsDefault)
+	assert.Equal(t, uint32(0), result.IsActive)
+	assert.Equal(t, uint8(2), result.HashAlgorithm)
+	assert.Equal(t, uint8(0xcd), result.CertificateHash[1])
+}
+func TestDecodeCertHashEntryNameTooLong(t *testing.T) {
+	var bin_buf bytes.Buffer
+	writeCertHashEntry(&bin_buf, "VeriSign Class 3", 1024)
+	response := GetCertHashEntryResponse{}
+	_, err := decodeCertHashEntry(&bin_buf, &response)
+	assert.Error(t, err)
+}
+func TestDecodeCertHashEntryLengthMismatch(t *testing.T) {
+	var bin_buf bytes.Buffer
+	writeCertHashEntry(&bin_buf, "VeriSign Class 3", 16)
+	response := GetCertHashEntryResponse{}
+	response.Header.Header.Length = 4 + 4 + 4 + CERT_HASH_MAX_LENGTH + 1 + 2
+	_, err := decodeCertHashEntry(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func TestDecodePKIFQDNSuffix(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(12))
+	bin_buf.WriteString("vprodemo.com")
+	response := GetPKIFQDNSuffixResponse{}
+	response.Header.Header.Length = 4 + 2 + 12
+	result, err := decodePKIFQDNSuffix(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "vprodemo.com", result)
+}
+func TestDecodePKIFQDNSuffixLengthMismatch(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(12))
+	bin_buf.WriteString("vprodemo.com")
+	response := GetPKIFQDNSuffixResponse{}
+	response.Header.Header.Length = 4 + 2 + 253
+	_, err := decodePKIFQDNSuffix(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func TestDecodeDNSSuffixList(t *testing.T) {
+	var bin_buf bytes.Buffer
+	data := "vprodemo.com\u0000corp.vprodemo.com\u0000\u0000"
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(len(data)))
+	bin_buf.WriteString(data)
+	response := GetDNSSuffixListResponse{}
+	response.Header.Header.Length = 4 + 2 + uint32(len(data))
+	result, err := decodeDNSSuffixList(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vprodemo.com", "corp.vprodemo.com"}, result)
+}
+func TestDecodeDNSSuffixListTruncated(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(64))
+	bin_buf.WriteString("vprodemo.com")
+	response := GetDNSSuffixListResponse{}
+	response.Header.Header.Length = 4 + 2 + 64
+	_, err := decodeDNSSuffixList(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func TestDecodeFQDN(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{1, 0, 1440, 900, 17})
+	bin_buf.WriteString("host.vprodemo.com")
+	response := GetFQDNResponse{}
+	response.Header.Header.Length = 4 + 4 + 4 + 4 + 4 + 4 + 17
+	result, err := decodeFQDN(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, FQDN{
+		FQDN:                       "host.vprodemo.com",
+		SharedFQDN:                 true,
+		DDNSUpdateEnabled:          false,
+		DDNSPeriodicUpdateInterval: 1440,
+		DDNSTTL:                    900,
+		HostNameLength:             17,
+	}, result)
+}
+func TestDecodeFQDNTruncated(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{1, 0})
+	response := GetFQDNResponse{}
+	_, err := decodeFQDN(&bin_buf, &response)
+	assert.Error(t, err)
+}
+func TestDecodeFQDNLengthExceedsMaximum(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{1, 0, 0, 0, FQDN_MAX_SIZE + 1})
+	bin_buf.Write(make([]byte, FQDN_MAX_SIZE+1))
+	response := GetFQDNResponse{}
+	response.Header.Header.Length = 4 + 4 + 4 + 4 + 4 + 4 + FQDN_MAX_SIZE + 1
+	_, err := decodeFQDN(&bin_buf, &response)
+	assert.EqualError(t, err, "fqdn length exceeds maximum")
+}
+func TestDecodeFQDNLengthExceedsResponse(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{1, 0, 0, 0, 17})
+	bin_buf.WriteString("host")
+	response := GetFQDNResponse{}
+	response.Header.Header.Length = 4 + 4 + 4 + 4 + 4 + 4 + 17
+	_, err := decodeFQDN(&bin_buf, &response)
+	assert.EqualError(t, err, "fqdn length exceeds response")
+}
+
+func TestDecodeRemoteAccessConnectionStatus(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{2, 2, 1})
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(16))
+	bin_buf.WriteString("mps.vprodemo.com")
+	response := GetRemoteAccessConnectionStatusResponse{}
+	response.Header.Header.Length = 4 + 12 + 2 + 16
+	result, err := decodeRemoteAccessConnectionStatus(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, RemoteAccessStatus{
+		NetworkConnectionStatus:       2,
+		RemoteAccessConnectionStatus:  2,
+		RemoteAccessConnectionTrigger: 1,
+		MPSHostname:                   "mps.vprodemo.com",
+	}, result)
+}
+func TestDecodeRemoteAccessConnectionStatusHostnameTooLong(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, []uint32{2, 2, 1})
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(MPS_HOSTNAME_LENGTH+1))
+	bin_buf.Write(make([]byte, MPS_HOSTNAME_LENGTH+1))
+	response := GetRemoteAccessConnectionStatusResponse{}
+	_, err := decodeRemoteAccessConnectionStatus(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func TestDecodeCurrentPowerPolicy(t *testing.T) {
+	var bin_buf bytes.Buffer
+	policyName := "Desktop: ON in S0"
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(len(policyName)))
+	bin_buf.WriteString(policyName)
+	response := GetCurrentPowerPolicyResponse{}
+	response.Header.Header.Length = uint32(4 + 2 + len(policyName))
+	result, err := decodeCurrentPowerPolicy(&bin_buf, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, policyName, result)
+}
+func TestDecodeCurrentPowerPolicyOverrun(t *testing.T) {
+	var bin_buf bytes.Buffer
+	binary.Write(&bin_buf, binary.LittleEndian, uint16(64))
+	bin_buf.WriteString("Desktop")
+	response := GetCurrentPowerPolicyResponse{}
+	response.Header.Header.Length = 4 + 2 + 64
+	_, err := decodeCurrentPowerPolicy(&bin_buf, &response)
+	assert.Error(t, err)
+}
+
+func TestWriteAMTANSIString(t *testing.T) {
+	var bin_buf bytes.Buffer
+	err := writeAMTANSIString(&bin_buf, "corp.example.com", FQDN_MAX_SIZE)
+	assert.NoError(t, err)
+	result, err := readAMTANSIString(&bin_buf, FQDN_MAX_SIZE)
+	assert.NoError(t, err)
+	assert.Equal(t, "corp.example.com", result)
+}
+func TestWriteAMTANSIStringTooLong(t *testing.T) {
+	var bin_buf bytes.Buffer
+	err := writeAMTANSIString(&bin_buf, string(make([]byte, FQDN_MAX_SIZE+1)), FQDN_MAX_SIZE)
+	assert.Error(t, err)
+	assert.Equal(t, 0, bin_buf.Len())
+}
+
+func TestEncodeEnterpriseAccessIPv4(t *testing.T) {
+	command := SetEnterpriseAccessRequest{}
+	err := encodeEnterpriseAccess(&command, net.ParseIP("192.168.1.100"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(0), command.Flags)
+	assert.Equal(t, []uint8{192, 168, 1, 100}, command.HostIPAddress[:4])
+	assert.Equal(t, make([]uint8, 12), command.HostIPAddress[4:])
+	assert.Equal(t, uint8(1), command.EnterpriseAccess)
+	assert.Equal(t, 12+18, binary.Size(command))
+}
+func TestEncodeEnterpriseAccessIPv6(t *testing.T) {
+	command := SetEnterpriseAccessRequest{}
+	err := encodeEnterpriseAccess(&command, net.ParseIP("fe80::1"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), command.Flags)
+	assert.Equal(t, uint8(0xfe), command.HostIPAddress[0])
+	assert.Equal(t, uint8(0), command.EnterpriseAccess)
+}
+func TestEncodeEnterpriseAccessInvalid(t *testing.T) {
+	command := SetEnterpriseAccessRequest{}
+	err := encodeEnterpriseAccess(&command, nil, true)
+	assert.Error(t, err)
+}
+
+func TestLocalSystemAccountWipe(t *testing.T) {
+	account := LocalSystemAccount{}
+	copy(account.Username[:], "$$OsAdmin")
+	copy(account.Password[:], "P@ssw0rd")
+	account.Wipe()
+	assert.Equal(t, LocalSystemAccount{}, account)
+}
+func TestLocalSystemAccountString(t *testing.T) {
+	account := LocalSystemAccount{}
+	copy(account.Username[:], "$$OsAdmin")
+	copy(account.Password[:], "P@ssw0rd")
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", account, account, account, account), "P@ssw0rd")
+}
+func TestWipe(t *testing.T) {
+	buffer := []byte("P@ssw0rd")
+	wipe(buffer[:2])
+	assert.Equal(t, make([]byte, 8), buffer)
+}
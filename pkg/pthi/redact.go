@@ -0,0 +1,33 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"encoding/binary"
+	"rpc/pkg/heci"
+)
+
+// RedactSecrets is a heci.Tracer Redact function. It zeroes the local system account password in
+// responses and the one time password in provisioning requests, leaving the message lengths intact.
+func RedactSecrets(op string, data []byte) ([]byte, bool) {
+	if len(data) < requestHeaderSize {
+		return nil, false
+	}
+	command := binary.LittleEndian.Uint32(data[4:8])
+	var secret int
+	switch {
+	case op == heci.TraceReceive && command == GET_LOCAL_SYSTEM_ACCOUNT_RESPONSE:
+		secret = responseHeaderSize + CFG_MAX_ACL_USER_LENGTH
+	case op == heci.TraceSend && command == SET_PROVISIONING_SERVER_OTP_REQUEST:
+		secret = requestHeaderSize + 2
+	default:
+		return nil, false
+	}
+	redacted := append([]byte{}, data...)
+	for i := secret; i < len(redacted); i++ {
+		redacted[i] = 0
+	}
+	return redacted, true
+}
@@ -0,0 +1,199 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import "fmt"
+
+// AMTStatus is the status AMT reports in every PTHI response header. Commands return it as
+// their error whenever the firmware reports anything other than AMT_STATUS_SUCCESS, so callers
+// can test for a particular status with errors.Is(err, pthi.AMT_STATUS_NOT_PERMITTED), which
+// compares the values even through %w wrapping, or extract it with errors.As.
+type AMTStatus uint32
+
+// AMT_STATUS_* mirror microlms/heci/StatusCodeDefinitions.h
+const (
+	AMT_STATUS_SUCCESS                         AMTStatus = 0x0
+	AMT_STATUS_INTERNAL_ERROR                  AMTStatus = 0x1
+	AMT_STATUS_NOT_READY                       AMTStatus = 0x2
+	AMT_STATUS_INVALID_PT_MODE                 AMTStatus = 0x3
+	AMT_STATUS_INVALID_MESSAGE_LENGTH          AMTStatus = 0x4
+	AMT_STATUS_TABLE_FINGERPRINT_NOT_AVAILABLE AMTStatus = 0x5
+	AMT_STATUS_INTEGRITY_CHECK_FAILED          AMTStatus = 0x6
+	AMT_STATUS_UNSUPPORTED_ISVS_VERSION        AMTStatus = 0x7
+	AMT_STATUS_APPLICATION_NOT_REGISTERED      AMTStatus = 0x8
+	AMT_STATUS_INVALID_REGISTRATION_DATA       AMTStatus = 0x9
+	AMT_STATUS_APPLICATION_DOES_NOT_EXIST      AMTStatus = 0xA
+	AMT_STATUS_NOT_ENOUGH_STORAGE              AMTStatus = 0xB
+	AMT_STATUS_INVALID_NAME                    AMTStatus = 0xC
+	AMT_STATUS_BLOCK_DOES_NOT_EXIST            AMTStatus = 0xD
+	AMT_STATUS_INVALID_BYTE_OFFSET             AMTStatus = 0xE
+	AMT_STATUS_INVALID_BYTE_COUNT              AMTStatus = 0xF
+	AMT_STATUS_NOT_PERMITTED                   AMTStatus = 0x10
+	AMT_STATUS_NOT_OWNER                       AMTStatus = 0x11
+	AMT_STATUS_BLOCK_LOCKED_BY_OTHER           AMTStatus = 0x12
+	AMT_STATUS_BLOCK_NOT_LOCKED                AMTStatus = 0x13
+	AMT_STATUS_INVALID_GROUP_PERMISSIONS       AMTStatus = 0x14
+	AMT_STATUS_GROUP_DOES_NOT_EXIST            AMTStatus = 0x15
+	AMT_STATUS_INVALID_MEMBER_COUNT            AMTStatus = 0x16
+	AMT_STATUS_MAX_LIMIT_REACHED               AMTStatus = 0x17
+	AMT_STATUS_INVALID_AUTH_TYPE               AMTStatus = 0x18
+	AMT_STATUS_AUTHENTICATION_FAILED           AMTStatus = 0x19
+	AMT_STATUS_INVALID_DHCP_MODE               AMTStatus = 0x1A
+	AMT_STATUS_INVALID_IP_ADDRESS              AMTStatus = 0x1B
+	AMT_STATUS_INVALID_DOMAIN_NAME             AMTStatus = 0x1C
+	AMT_STATUS_UNSUPPORTED_VERSION             AMTStatus = 0x1D
+	AMT_STATUS_REQUEST_UNEXPECTED              AMTStatus = 0x1E
+	AMT_STATUS_INVALID_TABLE_TYPE              AMTStatus = 0x1F
+	AMT_STATUS_INVALID_PROVISIONING_STATE      AMTStatus = 0x20
+	AMT_STATUS_UNSUPPORTED_OBJECT              AMTStatus = 0x21
+	AMT_STATUS_INVALID_TIME                    AMTStatus = 0x22
+	AMT_STATUS_INVALID_INDEX                   AMTStatus = 0x23
+	AMT_STATUS_INVALID_PARAMETER               AMTStatus = 0x24
+	AMT_STATUS_INVALID_NETMASK                 AMTStatus = 0x25
+	AMT_STATUS_FLASH_WRITE_LIMIT_EXCEEDED      AMTStatus = 0x26
+	AMT_STATUS_INVALID_IMAGE_LENGTH            AMTStatus = 0x27
+	AMT_STATUS_INVALID_IMAGE_SIGNATURE         AMTStatus = 0x28
+	AMT_STATUS_PROPOSE_ANOTHER_VERSION         AMTStatus = 0x29
+	AMT_STATUS_INVALID_PID_FORMAT              AMTStatus = 0x2A
+	AMT_STATUS_INVALID_PPS_FORMAT              AMTStatus = 0x2B
+	AMT_STATUS_BIST_COMMAND_BLOCKED            AMTStatus = 0x2C
+	AMT_STATUS_CONNECTION_FAILED               AMTStatus = 0x2D
+	AMT_STATUS_CONNECTION_TOO_MANY             AMTStatus = 0x2E
+	AMT_STATUS_RNG_GENERATION_IN_PROGRESS      AMTStatus = 0x2F
+	AMT_STATUS_RNG_NOT_READY                   AMTStatus = 0x30
+	AMT_STATUS_CERTIFICATE_NOT_READY           AMTStatus = 0x31
+	AMT_STATUS_NETWORK_IF_ERROR_BASE           AMTStatus = 0x800
+	AMT_STATUS_UNSUPPORTED_OEM_NUMBER          AMTStatus = 0x801
+	AMT_STATUS_UNSUPPORTED_BOOT_OPTION         AMTStatus = 0x802
+	AMT_STATUS_INVALID_COMMAND                 AMTStatus = 0x803
+	AMT_STATUS_INVALID_SPECIAL_COMMAND         AMTStatus = 0x804
+	AMT_STATUS_INVALID_HANDLE                  AMTStatus = 0x805
+	AMT_STATUS_INVALID_PASSWORD                AMTStatus = 0x806
+	AMT_STATUS_INVALID_REALM                   AMTStatus = 0x807
+	AMT_STATUS_STORAGE_ACL_ENTRY_IN_USE        AMTStatus = 0x808
+	AMT_STATUS_DATA_MISSING                    AMTStatus = 0x809
+	AMT_STATUS_DUPLICATE                       AMTStatus = 0x80A
+	AMT_STATUS_EVENTLOG_FROZEN                 AMTStatus = 0x80B
+	AMT_STATUS_PKI_MISSING_KEYS                AMTStatus = 0x80C
+	AMT_STATUS_PKI_GENERATING_KEYS             AMTStatus = 0x80D
+	AMT_STATUS_INVALID_KEY                     AMTStatus = 0x80E
+	AMT_STATUS_INVALID_CERT                    AMTStatus = 0x80F
+	AMT_STATUS_CERT_KEY_NOT_MATCH              AMTStatus = 0x810
+	AMT_STATUS_MAX_KERB_DOMAIN_REACHED         AMTStatus = 0x811
+	AMT_STATUS_UNSUPPORTED                     AMTStatus = 0x812
+	AMT_STATUS_INVALID_PRIORITY                AMTStatus = 0x813
+	AMT_STATUS_NOT_FOUND                       AMTStatus = 0x814
+	AMT_STATUS_INVALID_CREDENTIALS             AMTStatus = 0x815
+	AMT_STATUS_INVALID_PASSPHRASE              AMTStatus = 0x816
+	AMT_STATUS_NO_ASSOCIATION                  AMTStatus = 0x818
+	AMT_STATUS_AUDIT_FAIL                      AMTStatus = 0x81B
+	AMT_STATUS_BLOCKING_COMPONENT              AMTStatus = 0x81C
+)
+
+type amtStatusText struct {
+	name    string
+	message string
+}
+
+var amtStatusTexts = map[AMTStatus]amtStatusText{
+	AMT_STATUS_SUCCESS:                         {"AMT_STATUS_SUCCESS", "request succeeded"},
+	AMT_STATUS_INTERNAL_ERROR:                  {"AMT_STATUS_INTERNAL_ERROR", "an internal error in the AMT device has occurred"},
+	AMT_STATUS_NOT_READY:                       {"AMT_STATUS_NOT_READY", "AMT has not progressed far enough in its initialization to process the command"},
+	AMT_STATUS_INVALID_PT_MODE:                 {"AMT_STATUS_INVALID_PT_MODE", "command is not permitted in the current operating mode"},
+	AMT_STATUS_INVALID_MESSAGE_LENGTH:          {"AMT_STATUS_INVALID_MESSAGE_LENGTH", "length field of the header is invalid"},
+	AMT_STATUS_TABLE_FINGERPRINT_NOT_AVAILABLE: {"AMT_STATUS_TABLE_FINGERPRINT_NOT_AVAILABLE", "the requested hardware asset inventory table checksum is not available"},
+	AMT_STATUS_INTEGRITY_CHECK_FAILED:          {"AMT_STATUS_INTEGRITY_CHECK_FAILED", "the integrity check value of the request is invalid"},
+	AMT_STATUS_UNSUPPORTED_ISVS_VERSION:        {"AMT_STATUS_UNSUPPORTED_ISVS_VERSION", "the specified ISV version is not supported"},
+	AMT_STATUS_APPLICATION_NOT_REGISTERED:      {"AMT_STATUS_APPLICATION_NOT_REGISTERED", "the queried application is not registered"},
+	AMT_STATUS_INVALID_REGISTRATION_DATA:       {"AMT_STATUS_INVALID_REGISTRATION_DATA", "an invalid or unregistered enterprise name was specified"},
+	AMT_STATUS_APPLICATION_DOES_NOT_EXIST:      {"AMT_STATUS_APPLICATION_DOES_NOT_EXIST", "the application handle has never been allocated"},
+	AMT_STATUS_NOT_ENOUGH_STORAGE:              {"AMT_STATUS_NOT_ENOUGH_STORAGE", "the requested number of bytes cannot be allocated in ISV storage"},
+	AMT_STATUS_INVALID_NAME:                    {"AMT_STATUS_INVALID_NAME", "the specified name is invalid"},
+	AMT_STATUS_BLOCK_DOES_NOT_EXIST:            {"AMT_STATUS_BLOCK_DOES_NOT_EXIST", "the specified block does not exist"},
+	AMT_STATUS_INVALID_BYTE_OFFSET:             {"AMT_STATUS_INVALID_BYTE_OFFSET", "the specified byte offset is invalid"},
+	AMT_STATUS_INVALID_BYTE_COUNT:              {"AMT_STATUS_INVALID_BYTE_COUNT", "the specified byte count is invalid"},
+	AMT_STATUS_NOT_PERMITTED:                   {"AMT_STATUS_NOT_PERMITTED", "the operation is not permitted"},
+	AMT_STATUS_NOT_OWNER:                       {"AMT_STATUS_NOT_OWNER", "the requesting application is not the owner of the block"},
+	AMT_STATUS_BLOCK_LOCKED_BY_OTHER:           {"AMT_STATUS_BLOCK_LOCKED_BY_OTHER", "the specified block is locked by another application"},
+	AMT_STATUS_BLOCK_NOT_LOCKED:                {"AMT_STATUS_BLOCK_NOT_LOCKED", "the specified block is not locked"},
+	AMT_STATUS_INVALID_GROUP_PERMISSIONS:       {"AMT_STATUS_INVALID_GROUP_PERMISSIONS", "the specified group permission bits are invalid"},
+	AMT_STATUS_GROUP_DOES_NOT_EXIST:            {"AMT_STATUS_GROUP_DOES_NOT_EXIST", "the specified group does not exist"},
+	AMT_STATUS_INVALID_MEMBER_COUNT:            {"AMT_STATUS_INVALID_MEMBER_COUNT", "the specified member count is invalid"},
+	AMT_STATUS_MAX_LIMIT_REACHED:               {"AMT_STATUS_MAX_LIMIT_REACHED", "a maximum limit associated with the request has been reached"},
+	AMT_STATUS_INVALID_AUTH_TYPE:               {"AMT_STATUS_INVALID_AUTH_TYPE", "the specified key algorithm is invalid"},
+	AMT_STATUS_AUTHENTICATION_FAILED:           {"AMT_STATUS_AUTHENTICATION_FAILED", "authentication failed"},
+	AMT_STATUS_INVALID_DHCP_MODE:               {"AMT_STATUS_INVALID_DHCP_MODE", "the specified DHCP mode is invalid"},
+	AMT_STATUS_INVALID_IP_ADDRESS:              {"AMT_STATUS_INVALID_IP_ADDRESS", "the specified IP address is not a valid unicast address"},
+	AMT_STATUS_INVALID_DOMAIN_NAME:             {"AMT_STATUS_INVALID_DOMAIN_NAME", "the specified domain name is not valid"},
+	AMT_STATUS_UNSUPPORTED_VERSION:             {"AMT_STATUS_UNSUPPORTED_VERSION", "the version is not supported"},
+	AMT_STATUS_REQUEST_UNEXPECTED:              {"AMT_STATUS_REQUEST_UNEXPECTED", "a prerequisite request has not been received"},
+	AMT_STATUS_INVALID_TABLE_TYPE:              {"AMT_STATUS_INVALID_TABLE_TYPE", "the table type is invalid"},
+	AMT_STATUS_INVALID_PROVISIONING_STATE:      {"AMT_STATUS_INVALID_PROVISIONING_STATE", "the command is not allowed in the current provisioning state"},
+	AMT_STATUS_UNSUPPORTED_OBJECT:              {"AMT_STATUS_UNSUPPORTED_OBJECT", "the object is not supported"},
+	AMT_STATUS_INVALID_TIME:                    {"AMT_STATUS_INVALID_TIME", "the specified time is earlier than the baseline time of the device"},
+	AMT_STATUS_INVALID_INDEX:                   {"AMT_STATUS_INVALID_INDEX", "the starting index is invalid"},
+	AMT_STATUS_INVALID_PARAMETER:               {"AMT_STATUS_INVALID_PARAMETER", "a parameter is invalid"},
+	AMT_STATUS_INVALID_NETMASK:                 {"AMT_STATUS_INVALID_NETMASK", "the specified netmask is invalid"},
+	AMT_STATUS_FLASH_WRITE_LIMIT_EXCEEDED:      {"AMT_STATUS_FLASH_WRITE_LIMIT_EXCEEDED", "flash wear-out protection prevented a write to NVRAM"},
+	AMT_STATUS_INVALID_IMAGE_LENGTH:            {"AMT_STATUS_INVALID_IMAGE_LENGTH", "the firmware did not receive the entire image file"},
+	AMT_STATUS_INVALID_IMAGE_SIGNATURE:         {"AMT_STATUS_INVALID_IMAGE_SIGNATURE", "the firmware received an image file with an invalid signature"},
+	AMT_STATUS_PROPOSE_ANOTHER_VERSION:         {"AMT_STATUS_PROPOSE_ANOTHER_VERSION", "LME can not support the requested version"},
+	AMT_STATUS_INVALID_PID_FORMAT:              {"AMT_STATUS_INVALID_PID_FORMAT", "the PID must be 8 characters of capital letters and digits"},
+	AMT_STATUS_INVALID_PPS_FORMAT:              {"AMT_STATUS_INVALID_PPS_FORMAT", "the PPS must be 32 characters of capital letters and digits"},
+	AMT_STATUS_BIST_COMMAND_BLOCKED:            {"AMT_STATUS_BIST_COMMAND_BLOCKED", "the full BIST test has been blocked"},
+	AMT_STATUS_CONNECTION_FAILED:               {"AMT_STATUS_CONNECTION_FAILED", "a TCP/IP connection could not be opened on the selected port"},
+	AMT_STATUS_CONNECTION_TOO_MANY:             {"AMT_STATUS_CONNECTION_TOO_MANY", "the maximum number of connections has been reached"},
+	AMT_STATUS_RNG_GENERATION_IN_PROGRESS:      {"AMT_STATUS_RNG_GENERATION_IN_PROGRESS", "random key generation is in progress"},
+	AMT_STATUS_RNG_NOT_READY:                   {"AMT_STATUS_RNG_NOT_READY", "a randomly generated key does not exist"},
+	AMT_STATUS_CERTIFICATE_NOT_READY:           {"AMT_STATUS_CERTIFICATE_NOT_READY", "the self-generated AMT certificate does not exist"},
+	AMT_STATUS_NETWORK_IF_ERROR_BASE:           {"AMT_STATUS_NETWORK_IF_ERROR_BASE", "network interface error"},
+	AMT_STATUS_UNSUPPORTED_OEM_NUMBER:          {"AMT_STATUS_UNSUPPORTED_OEM_NUMBER", "the OEM number of the remote control command is not supported"},
+	AMT_STATUS_UNSUPPORTED_BOOT_OPTION:         {"AMT_STATUS_UNSUPPORTED_BOOT_OPTION", "the boot option of the remote control command is not supported"},
+	AMT_STATUS_INVALID_COMMAND:                 {"AMT_STATUS_INVALID_COMMAND", "the remote control command is not supported"},
+	AMT_STATUS_INVALID_SPECIAL_COMMAND:         {"AMT_STATUS_INVALID_SPECIAL_COMMAND", "the special command of the remote control command is not supported"},
+	AMT_STATUS_INVALID_HANDLE:                  {"AMT_STATUS_INVALID_HANDLE", "the specified handle is invalid"},
+	AMT_STATUS_INVALID_PASSWORD:                {"AMT_STATUS_INVALID_PASSWORD", "the password is invalid"},
+	AMT_STATUS_INVALID_REALM:                   {"AMT_STATUS_INVALID_REALM", "the realm is invalid"},
+	AMT_STATUS_STORAGE_ACL_ENTRY_IN_USE:        {"AMT_STATUS_STORAGE_ACL_ENTRY_IN_USE", "the storage ACL entry is used by an active registration"},
+	AMT_STATUS_DATA_MISSING:                    {"AMT_STATUS_DATA_MISSING", "essential data is missing"},
+	AMT_STATUS_DUPLICATE:                       {"AMT_STATUS_DUPLICATE", "the value is a duplicate of an existing entry"},
+	AMT_STATUS_EVENTLOG_FROZEN:                 {"AMT_STATUS_EVENTLOG_FROZEN", "the event log is frozen"},
+	AMT_STATUS_PKI_MISSING_KEYS:                {"AMT_STATUS_PKI_MISSING_KEYS", "the device is missing private key material"},
+	AMT_STATUS_PKI_GENERATING_KEYS:             {"AMT_STATUS_PKI_GENERATING_KEYS", "the device is generating a key pair, try again later"},
+	AMT_STATUS_INVALID_KEY:                     {"AMT_STATUS_INVALID_KEY", "the key is invalid"},
+	AMT_STATUS_INVALID_CERT:                    {"AMT_STATUS_INVALID_CERT", "the X.509 certificate is invalid"},
+	AMT_STATUS_CERT_KEY_NOT_MATCH:              {"AMT_STATUS_CERT_KEY_NOT_MATCH", "the certificate chain and private key do not match"},
+	AMT_STATUS_MAX_KERB_DOMAIN_REACHED:         {"AMT_STATUS_MAX_KERB_DOMAIN_REACHED", "the maximum number of Kerberos domains has been reached"},
+	AMT_STATUS_UNSUPPORTED:                     {"AMT_STATUS_UNSUPPORTED", "the requested configuration is unsupported"},
+	AMT_STATUS_INVALID_PRIORITY:                {"AMT_STATUS_INVALID_PRIORITY", "a profile with the requested priority already exists"},
+	AMT_STATUS_NOT_FOUND:                       {"AMT_STATUS_NOT_FOUND", "the specified element was not found"},
+	AMT_STATUS_INVALID_CREDENTIALS:             {"AMT_STATUS_INVALID_CREDENTIALS", "the user credentials are invalid"},
+	AMT_STATUS_INVALID_PASSPHRASE:              {"AMT_STATUS_INVALID_PASSPHRASE", "the passphrase is invalid"},
+	AMT_STATUS_NO_ASSOCIATION:                  {"AMT_STATUS_NO_ASSOCIATION", "a certificate handle must be chosen first"},
+	AMT_STATUS_AUDIT_FAIL:                      {"AMT_STATUS_AUDIT_FAIL", "the command is an audit log event and could not be logged"},
+	AMT_STATUS_BLOCKING_COMPONENT:              {"AMT_STATUS_BLOCKING_COMPONENT", "an ME component is not ready for unprovisioning"},
+}
+
+// Name returns the AMT_STATUS_* name of the status, or UNKNOWN for a status missing from the table
+func (status AMTStatus) Name() string {
+	text, ok := amtStatusTexts[status]
+	if !ok {
+		return "UNKNOWN"
+	}
+	return text.name
+}
+
+// Message describes the status, or returns an empty string for a status missing from the table
+func (status AMTStatus) Message() string {
+	return amtStatusTexts[status].message
+}
+
+func (status AMTStatus) Error() string {
+	message := status.Message()
+	if message == "" {
+		return fmt.Sprintf("amt returned status %d (%s)", uint32(status), status.Name())
+	}
+	return fmt.Sprintf("amt returned status %d (%s): %s", uint32(status), status.Name(), message)
+}
@@ -0,0 +1,43 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusErrorSuccess(t *testing.T) {
+	assert.NoError(t, statusError(0))
+}
+func TestStatusErrorReturnsAMTStatus(t *testing.T) {
+	err := statusError(0x10)
+	assert.Equal(t, AMT_STATUS_NOT_PERMITTED, err)
+	assert.EqualError(t, err, "amt returned status 16 (AMT_STATUS_NOT_PERMITTED): the operation is not permitted")
+}
+func TestAMTStatusName(t *testing.T) {
+	assert.Equal(t, "AMT_STATUS_INVALID_PROVISIONING_STATE", AMT_STATUS_INVALID_PROVISIONING_STATE.Name())
+	assert.Equal(t, "AMT_STATUS_BLOCKING_COMPONENT", AMT_STATUS_BLOCKING_COMPONENT.Name())
+}
+func TestAMTStatusUnknown(t *testing.T) {
+	status := AMTStatus(0x1234)
+	assert.Equal(t, "UNKNOWN", status.Name())
+	assert.Equal(t, "", status.Message())
+	assert.EqualError(t, status, "amt returned status 4660 (UNKNOWN)")
+}
+func TestAMTStatusIsWrapped(t *testing.T) {
+	err := fmt.Errorf("unable to deactivate: %w", statusError(0x20))
+	assert.True(t, errors.Is(err, AMT_STATUS_INVALID_PROVISIONING_STATE))
+	assert.False(t, errors.Is(err, AMT_STATUS_NOT_PERMITTED))
+}
+func TestAMTStatusAs(t *testing.T) {
+	err := fmt.Errorf("unable to set dns suffix: %w", statusError(0x24))
+	var status AMTStatus
+	assert.True(t, errors.As(err, &status))
+	assert.Equal(t, AMT_STATUS_INVALID_PARAMETER, status)
+}
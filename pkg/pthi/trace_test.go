@@ -0,0 +1,189 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"rpc/pkg/heci"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// record runs commands over a fake transport wrapped by a tracer and returns the trace
+func record(fake *heci.Fake, commands func(pthi PTHICommand)) *bytes.Buffer {
+	trace := &bytes.Buffer{}
+	tracer := heci.NewTracer(trace, heci.PTHIClientGUID)
+	tracer.Redact = RedactSecrets
+	pthi := NewPTHICommandWithTransport(tracer.Wrap(fake))
+	commands(pthi)
+	pthi.Close()
+	return trace
+}
+
+func TestTraceReplay(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, uint32(1)))
+	fake.Respond(GET_UUID_REQUEST, newResponse(GET_UUID_REQUEST, 0, [16]uint8{0x12, 0x34}))
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+		pthi.GetUUID()
+	})
+	assert.Equal(t, 6, strings.Count(trace.String(), "\n"))
+	assert.Contains(t, trace.String(), `"guid":"`+heci.PTHIClientGUID+`"`)
+
+	replay, err := heci.ReadReplay(trace)
+	assert.NoError(t, err)
+	pthi := NewPTHICommandWithTransport(replay)
+	mode, err := pthi.GetControlMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mode)
+	uuid, err := pthi.GetUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x12), uuid[0])
+	mode, err = pthi.GetControlMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mode)
+}
+func TestTraceReplaySubset(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, uint32(1)))
+	fake.Respond(GET_UUID_REQUEST, newResponse(GET_UUID_REQUEST, 0, [16]uint8{0x12, 0x34}))
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+		pthi.GetUUID()
+	})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	uuid, err := pthi.GetUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x12), uuid[0])
+}
+func TestTraceReplayMismatch(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, uint32(1)))
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+	})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetUUID()
+	assert.EqualError(t, err, "trace has no record of the request")
+}
+func TestTraceReplayErrors(t *testing.T) {
+	fake := heci.NewFake()
+	fake.ReceiveErr = errors.New("device gone")
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+	})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetControlMode()
+	assert.EqualError(t, err, "device gone")
+}
+func TestTraceReplayAccessError(t *testing.T) {
+	fake := heci.NewFake()
+	fake.InitErr = &heci.AccessError{
+		Failure: heci.AccessDenied,
+		Device:  "/dev/mei0",
+		Group:   "mei",
+		Err:     &os.PathError{Op: "open", Path: "/dev/mei0", Err: syscall.EACCES},
+	}
+	trace := record(fake, func(pthi PTHICommand) {})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetControlMode()
+	accessErr := &heci.AccessError{}
+	assert.True(t, errors.As(err, &accessErr))
+	assert.Equal(t, heci.AccessDenied, accessErr.Failure)
+	assert.Equal(t, "mei", accessErr.Group)
+	assert.True(t, errors.Is(err, syscall.EACCES))
+	assert.EqualError(t, err, fake.InitErr.Error())
+}
+func TestTraceReplayTimeout(t *testing.T) {
+	fake := heci.NewFake()
+	fake.ReceiveErr = heci.ErrTimeout
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+	})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetControlMode()
+	assert.True(t, errors.Is(err, heci.ErrTimeout))
+}
+func TestTraceReplayErrno(t *testing.T) {
+	fake := heci.NewFake()
+	fake.SendErr = syscall.ENODEV
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetControlMode()
+	})
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetControlMode()
+	assert.True(t, errors.Is(err, syscall.ENODEV))
+}
+func TestTraceReplayMEReset(t *testing.T) {
+	replay, err := heci.ReadReplay(strings.NewReader(`{"op":"init","bufferSize":5120}
+{"op":"send","data":"0110000011000004000000","error":"the ME was reset and dropped the request: no such device","errorKind":"reset","errno":19}
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, replay.Init())
+	_, err = replay.SendMessage([]byte{1, 16, 0, 0, 17, 0, 0, 4, 0, 0, 0}, nil)
+	assert.True(t, errors.Is(err, heci.ErrMEReset))
+	assert.True(t, errors.Is(err, syscall.Errno(19)))
+	assert.EqualError(t, err, "the ME was reset and dropped the request: no such device")
+}
+func TestTraceReplayNoInit(t *testing.T) {
+	replay, _ := heci.ReadReplay(strings.NewReader(""))
+	pthi := NewPTHICommandWithTransport(replay)
+	_, err := pthi.GetControlMode()
+	assert.EqualError(t, err, "trace has no init record")
+}
+func TestTraceReplayInvalid(t *testing.T) {
+	_, err := heci.ReadReplay(strings.NewReader("{\"op\":\"init\"}\nnot json\n"))
+	assert.EqualError(t, err, "invalid trace record on line 2: invalid character 'o' in literal null (expecting 'u')")
+}
+
+func TestTraceRedactsLocalSystemAccount(t *testing.T) {
+	account := LocalSystemAccount{}
+	copy(account.Username[:], "$$OsAdmin")
+	copy(account.Password[:], "P@ssw0rd")
+	fake := heci.NewFake()
+	fake.Respond(GET_LOCAL_SYSTEM_ACCOUNT_REQUEST, newResponse(GET_LOCAL_SYSTEM_ACCOUNT_REQUEST, 0, account))
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.GetLocalSystemAccount()
+	})
+	assert.NotContains(t, trace.String(), "4040737377307264")
+	assert.Contains(t, trace.String(), `"redacted":true`)
+
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	result, err := pthi.GetLocalSystemAccount()
+	assert.NoError(t, err)
+	assert.Equal(t, "$$OsAdmin", strings.TrimRight(string(result.Username[:]), "\x00"))
+	assert.Equal(t, [CFG_MAX_ACL_PWD_LENGTH]uint8{}, result.Password)
+}
+func TestTraceRedactsOTP(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(SET_PROVISIONING_SERVER_OTP_REQUEST, newResponse(SET_PROVISIONING_SERVER_OTP_REQUEST, 0))
+	trace := record(fake, func(pthi PTHICommand) {
+		pthi.SetProvisioningServerOTP("Secret123")
+	})
+	assert.NotContains(t, trace.String(), "536563726574313233")
+
+	replay, _ := heci.ReadReplay(trace)
+	pthi := NewPTHICommandWithTransport(replay)
+	assert.NoError(t, pthi.SetProvisioningServerOTP("Other456"))
+}
+func TestRedactSecretsIgnoresOtherCommands(t *testing.T) {
+	_, ok := RedactSecrets(heci.TraceReceive, newResponse(GET_UUID_REQUEST, 0, [16]uint8{}))
+	assert.False(t, ok)
+	_, ok = RedactSecrets(heci.TraceSend, []byte{1, 2})
+	assert.False(t, ok)
+}
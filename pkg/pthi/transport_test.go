@@ -0,0 +1,364 @@
+/*********************************************************************
+ * Copyright (c) Intel Corporation 2021
+ * SPDX-License-Identifier: Apache-2.0
+ **********************************************************************/
+package pthi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"rpc/pkg/heci"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakePTHI returns a PTHICommand over a fake transport that answers command with a successful
+// response carrying payload
+func newFakePTHI(command uint32, payload ...interface{}) (PTHICommand, *heci.Fake) {
+	fake := heci.NewFake()
+	fake.Respond(command, newResponse(command, 0, payload...))
+	return NewPTHICommandWithTransport(fake), fake
+}
+
+func TestFakeGetUUID(t *testing.T) {
+	pthi, fake := newFakePTHI(GET_UUID_REQUEST, [16]uint8{0x12, 0x34})
+	result, err := pthi.GetUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, string([]byte{0x12, 0x34, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), result)
+	assert.Len(t, fake.Requests, 1)
+	assert.Len(t, fake.Requests[0], 12)
+}
+func TestFakeGetControlMode(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_CONTROL_MODE_REQUEST, uint32(2))
+	result, err := pthi.GetControlMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result)
+}
+func TestFakeGetCodeVersions(t *testing.T) {
+	bios := [BIOS_VERSION_LEN]uint8{}
+	copy(bios[:], "BIOS.1.0")
+	pthi, _ := newFakePTHI(CODE_VERSIONS_REQUEST, bios, uint32(1), AMTVersionType{Description: newUnicodeString("AMT"), Version: newUnicodeString("15.0.10")})
+	result, err := pthi.GetCodeVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, []AMTVersion{{"AMT", "15.0.10"}}, result.Versions)
+}
+func TestFakeEnumerateHashHandles(t *testing.T) {
+	pthi, _ := newFakePTHI(ENUMERATE_HASH_HANDLES_REQUEST, []uint32{2, 1, 2})
+	result, err := pthi.EnumerateHashHandles()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2}, result)
+}
+func TestFakeGetCertHashEntry(t *testing.T) {
+	var bin_buf bytes.Buffer
+	writeCertHashEntry(&bin_buf, "VeriSign Class 3", 16)
+	pthi, fake := newFakePTHI(GET_CERTHASH_ENTRY_REQUEST, bin_buf.Bytes())
+	result, err := pthi.GetCertHashEntry(5)
+	assert.NoError(t, err)
+	assert.Equal(t, "VeriSign Class 3", result.Name)
+	assert.Equal(t, uint32(5), binary.LittleEndian.Uint32(fake.Requests[0][12:16]))
+}
+func TestFakeGetDNSSuffix(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_PKI_FQDN_SUFFIX_REQUEST, uint16(12), []byte("vprodemo.com"))
+	result, err := pthi.GetDNSSuffix()
+	assert.NoError(t, err)
+	assert.Equal(t, "vprodemo.com", result)
+}
+func TestFakeGetDNSSuffixList(t *testing.T) {
+	data := "vprodemo.com\u0000corp.vprodemo.com\u0000\u0000"
+	pthi, _ := newFakePTHI(GET_DNS_SUFFIX_LIST_REQUEST, uint16(len(data)), []byte(data))
+	result, err := pthi.GetDNSSuffixList()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vprodemo.com", "corp.vprodemo.com"}, result)
+}
+func TestFakeGetFQDN(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_FQDN_REQUEST, uint32(1), uint32(0), uint32(1440), uint32(900), uint32(17), []byte("host.vprodemo.com"))
+	result, err := pthi.GetFQDN()
+	assert.NoError(t, err)
+	assert.Equal(t, "host.vprodemo.com", result.FQDN)
+	assert.True(t, result.SharedFQDN)
+}
+func TestFakeGetRemoteAccessConnectionStatus(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_REMOTE_ACCESS_CONNECTION_STATUS_REQUEST, []uint32{2, 2, 1}, uint16(16), []byte("mps.vprodemo.com"))
+	result, err := pthi.GetRemoteAccessConnectionStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, "mps.vprodemo.com", result.MPSHostname)
+}
+func TestFakeGetLANInterfaceSettingsWireless(t *testing.T) {
+	pthi, fake := newFakePTHI(GET_LAN_INTERFACE_SETTINGS_REQUEST, uint32(1), uint32(0xc0a80164), uint32(1), uint8(1), uint8(1), [6]uint8{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc})
+	result, err := pthi.GetLANInterfaceSettings(true)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.100", result.IPAddress.String())
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(fake.Requests[0][12:16]))
+}
+func TestFakeGetLocalSystemAccount(t *testing.T) {
+	account := LocalSystemAccount{}
+	copy(account.Username[:], "$$OsAdmin")
+	copy(account.Password[:], "P@ssw0rd")
+	pthi, fake := newFakePTHI(GET_LOCAL_SYSTEM_ACCOUNT_REQUEST, account)
+	result, err := pthi.GetLocalSystemAccount()
+	assert.NoError(t, err)
+	username, password := result.Credentials()
+	assert.Equal(t, "$$OsAdmin", username)
+	assert.Equal(t, "P@ssw0rd", password)
+	assert.Len(t, fake.Requests[0], 52)
+}
+func TestFakeGetProvisioningState(t *testing.T) {
+	pthi, _ := newFakePTHI(PROVISIONING_STATE_REQUEST, uint32(2))
+	result, err := pthi.GetProvisioningState()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), result)
+}
+func TestFakeGetProvisioningMode(t *testing.T) {
+	pthi, _ := newFakePTHI(PROVISIONING_MODE_REQUEST, uint32(CFG_PROVISIONING_MODE_ENTERPRISE), uint32(1))
+	result, err := pthi.GetProvisioningMode()
+	assert.NoError(t, err)
+	assert.Equal(t, ProvisioningMode{Mode: CFG_PROVISIONING_MODE_ENTERPRISE, LegacyMode: true}, result)
+}
+func TestFakeGetProvisioningTLSMode(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_PROVISIONING_TLS_MODE_REQUEST, uint32(2))
+	result, err := pthi.GetProvisioningTLSMode()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), result)
+}
+func TestFakeGetZeroTouchEnabled(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_ZERO_TOUCH_ENABLED_REQUEST, uint32(1))
+	result, err := pthi.GetZeroTouchEnabled()
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+func TestFakeGetEHBCState(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_EHBC_STATE_REQUEST, uint32(1))
+	result, err := pthi.GetEHBCState()
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+func TestFakeGetFeaturesState(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_FEATURES_STATE_REQUEST,
+		newResponse(GET_FEATURES_STATE_REQUEST, 0, uint32(REDIRECTION_SESSION), [3]uint32{0, 1, 0}),
+		newResponse(GET_FEATURES_STATE_REQUEST, 0, uint32(SYSTEM_DEFENSE), [3]uint32{1, 0, 0}),
+		newResponse(GET_FEATURES_STATE_REQUEST, 0, uint32(WEB_UI), [3]uint32{1, 0, 0}))
+	pthi := NewPTHICommandWithTransport(fake)
+	result, err := pthi.GetFeaturesState()
+	assert.NoError(t, err)
+	assert.Equal(t, FeaturesState{SOLOpen: true, SystemDefenseActivated: true, WebUIEnabled: true}, result)
+	assert.Len(t, fake.Requests, 3)
+}
+func TestFakeGetFeaturesStateMismatchedRequest(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_FEATURES_STATE_REQUEST, uint32(WEB_UI), [3]uint32{1, 0, 0})
+	_, err := pthi.GetFeaturesState()
+	assert.EqualError(t, err, "features state response does not match request")
+}
+func TestFakeGetProvisioningModeTruncated(t *testing.T) {
+	pthi, _ := newFakePTHI(PROVISIONING_MODE_REQUEST, uint32(1))
+	_, err := pthi.GetProvisioningMode()
+	assert.EqualError(t, err, "response is truncated, expected 8 bytes of payload but got 4")
+}
+func TestFakeGetLastHostResetReason(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_LAST_HOST_RESET_REASON_REQUEST, uint32(0), uint32(1633046400))
+	result, err := pthi.GetLastHostResetReason()
+	assert.NoError(t, err)
+	assert.Equal(t, LastHostResetReason{Reason: 0, RemoteControlTimeStamp: 1633046400}, result)
+}
+func TestFakeGetCurrentPowerPolicy(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_CURRENT_POWER_POLICY_REQUEST, uint16(17), []byte("Desktop: ON in S0"))
+	result, err := pthi.GetCurrentPowerPolicy()
+	assert.NoError(t, err)
+	assert.Equal(t, "Desktop: ON in S0", result)
+}
+func TestFakeGetMACAddresses(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_MAC_ADDRESSES_REQUEST, [6]uint8{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}, [6]uint8{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcd})
+	result, err := pthi.GetMACAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, "00:1b:21:aa:bb:cd", result.HostMAC.String())
+}
+func TestFakeGetRNGSeedStatus(t *testing.T) {
+	pthi, _ := newFakePTHI(GET_RNG_SEED_STATUS_REQUEST, uint32(RNG_STATUS_IN_PROGRESS))
+	result, err := pthi.GetRNGSeedStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(RNG_STATUS_IN_PROGRESS), result)
+}
+
+func TestFakeSetDNSSuffix(t *testing.T) {
+	pthi, fake := newFakePTHI(SET_DNS_SUFFIX_REQUEST)
+	err := pthi.SetDNSSuffix("vprodemo.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2+12), binary.LittleEndian.Uint32(fake.Requests[0][8:12]))
+	assert.Equal(t, "vprodemo.com", string(fake.Requests[0][14:]))
+}
+func TestFakeSetHostFQDN(t *testing.T) {
+	pthi, fake := newFakePTHI(SET_HOST_FQDN_REQUEST)
+	err := pthi.SetHostFQDN("host.vprodemo.com")
+	assert.NoError(t, err)
+	assert.Len(t, fake.Requests[0], 12+2+FQDN_MAX_SIZE)
+	assert.Equal(t, uint32(2+17), binary.LittleEndian.Uint32(fake.Requests[0][8:12]))
+	assert.Equal(t, uint16(17), binary.LittleEndian.Uint16(fake.Requests[0][12:14]))
+	assert.Equal(t, "host.vprodemo.com", string(fake.Requests[0][14:14+17]))
+	assert.Equal(t, make([]byte, FQDN_MAX_SIZE-17), fake.Requests[0][14+17:])
+}
+func TestFakeSetHostFQDNTooLong(t *testing.T) {
+	pthi, fake := newFakePTHI(SET_HOST_FQDN_REQUEST)
+	err := pthi.SetHostFQDN(string(make([]byte, FQDN_MAX_SIZE+1)))
+	assert.EqualError(t, err, "fqdn length exceeds maximum")
+	assert.Empty(t, fake.Requests)
+}
+func TestFakeSetProvisioningServerOTP(t *testing.T) {
+	pthi, fake := newFakePTHI(SET_PROVISIONING_SERVER_OTP_REQUEST)
+	err := pthi.SetProvisioningServerOTP("Passw0rd")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2+8), binary.LittleEndian.Uint32(fake.Requests[0][8:12]))
+}
+func TestFakeSetEnterpriseAccess(t *testing.T) {
+	pthi, fake := newFakePTHI(SET_ENTERPRISE_ACCESS_REQUEST)
+	err := pthi.SetEnterpriseAccess(net.ParseIP("192.168.1.100"), true)
+	assert.NoError(t, err)
+	assert.Len(t, fake.Requests[0], 30)
+	assert.Equal(t, uint32(18), binary.LittleEndian.Uint32(fake.Requests[0][8:12]))
+}
+func TestFakeHeaderOnlyCommands(t *testing.T) {
+	commands := map[uint32]func(pthi *PTHICommand) error{
+		OPEN_USER_INITIATED_CONNECTION_REQUEST:  (*PTHICommand).OpenUserInitiatedConnection,
+		CLOSE_USER_INITIATED_CONNECTION_REQUEST: (*PTHICommand).CloseUserInitiatedConnection,
+		START_CONFIGURATION_REQUEST:             (*PTHICommand).StartConfiguration,
+		STOP_CONFIGURATION_REQUEST:              (*PTHICommand).StopConfiguration,
+		GENERATE_RNG_SEED_REQUEST:               (*PTHICommand).GenerateRNGSeed,
+		UNPROVISION_REQUEST:                     (*PTHICommand).Unprovision,
+	}
+	for command, send := range commands {
+		pthi, fake := newFakePTHI(command)
+		assert.NoError(t, send(&pthi))
+		assert.Equal(t, command, binary.LittleEndian.Uint32(fake.Requests[0][4:8]))
+
+		fake.Respond(command, newResponse(command, uint32(AMT_STATUS_INVALID_PT_MODE)))
+		assert.Equal(t, AMT_STATUS_INVALID_PT_MODE, send(&pthi))
+	}
+}
+
+func TestFakeStatusError(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(PROVISIONING_STATE_REQUEST, newResponse(PROVISIONING_STATE_REQUEST, uint32(AMT_STATUS_NOT_READY)))
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetProvisioningState()
+	assert.True(t, errors.Is(err, AMT_STATUS_NOT_READY))
+}
+func TestFakeWrongResponseCommand(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_UUID_REQUEST, 0, [16]uint8{}))
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetControlMode()
+	assert.Error(t, err)
+}
+func TestFakeTruncatedResponse(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_UUID_REQUEST, newResponse(GET_UUID_REQUEST, 0, [8]uint8{}))
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetUUID()
+	assert.EqualError(t, err, "response is truncated, expected 16 bytes of payload but got 8")
+}
+
+// resetOnce is a fake whose first response is lost to an ME reset
+type resetOnce struct {
+	*heci.Fake
+	reset bool
+}
+
+func (transport *resetOnce) ReceiveMessage(buffer []byte, done *uint32) (uint32, error) {
+	if !transport.reset {
+		transport.reset = true
+		return 0, heci.ErrMEReset
+	}
+	return transport.Fake.ReceiveMessage(buffer, done)
+}
+
+func TestFakeMEResetRetriesRead(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, uint32(1)))
+	pthi := NewPTHICommandWithTransport(&resetOnce{Fake: fake})
+	result, err := pthi.GetControlMode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result)
+	assert.Len(t, fake.Requests, 2)
+}
+func TestFakeMEResetDoesNotRetryChange(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(UNPROVISION_REQUEST, newResponse(UNPROVISION_REQUEST, 0))
+	pthi := NewPTHICommandWithTransport(&resetOnce{Fake: fake})
+	err := pthi.Unprovision()
+	assert.True(t, errors.Is(err, heci.ErrMEReset))
+	assert.Len(t, fake.Requests, 1)
+}
+
+// locking is a fake that checks each exchange holds its device lock
+type locking struct {
+	*heci.Fake
+	locked   bool
+	unlocked bool
+	exposed  bool
+}
+
+func (transport *locking) LockDevice() error {
+	transport.locked = true
+	return nil
+}
+func (transport *locking) UnlockDevice() {
+	transport.locked = false
+	transport.unlocked = true
+}
+func (transport *locking) SendMessage(buffer []byte, done *uint32) (uint32, error) {
+	transport.exposed = transport.exposed || !transport.locked
+	return transport.Fake.SendMessage(buffer, done)
+}
+func (transport *locking) ReceiveMessage(buffer []byte, done *uint32) (uint32, error) {
+	transport.exposed = transport.exposed || !transport.locked
+	return transport.Fake.ReceiveMessage(buffer, done)
+}
+
+func TestFakeCallLocksDevice(t *testing.T) {
+	fake := heci.NewFake()
+	fake.Respond(GET_CONTROL_MODE_REQUEST, newResponse(GET_CONTROL_MODE_REQUEST, 0, uint32(1)))
+	transport := &locking{Fake: fake}
+	pthi := NewPTHICommandWithTransport(transport)
+	_, err := pthi.GetControlMode()
+	assert.NoError(t, err)
+	assert.False(t, transport.exposed)
+	assert.False(t, transport.locked)
+	assert.True(t, transport.unlocked)
+}
+func TestFakeUnscriptedCommand(t *testing.T) {
+	pthi := NewPTHICommandWithTransport(heci.NewFake())
+	_, err := pthi.GetControlMode()
+	assert.EqualError(t, err, "no response scripted for command 0x0400006B")
+}
+func TestFakeInitError(t *testing.T) {
+	fake := heci.NewFake()
+	fake.InitErr = errors.New("no such device")
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetControlMode()
+	assert.EqualError(t, err, "no such device")
+	assert.Empty(t, fake.Requests)
+	pthi.Close()
+	assert.False(t, fake.Closed)
+}
+func TestFakeReceiveError(t *testing.T) {
+	fake := heci.NewFake()
+	fake.ReceiveErr = errors.New("device disconnected")
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetControlMode()
+	assert.EqualError(t, err, "device disconnected")
+}
+func TestFakeClose(t *testing.T) {
+	pthi, fake := newFakePTHI(GET_CONTROL_MODE_REQUEST, uint32(0))
+	pthi.Close()
+	assert.True(t, fake.Closed)
+}
+func TestFakeTimeout(t *testing.T) {
+	fake := heci.NewFake()
+	fake.ReceiveErr = heci.ErrTimeout
+	pthi := NewPTHICommandWithTransport(fake)
+	_, err := pthi.GetControlMode()
+	assert.True(t, errors.Is(err, heci.ErrTimeout))
+	assert.EqualError(t, err, "no response to command 0x0400006B: timed out waiting for the ME to respond")
+}
@@ -4,6 +4,8 @@
  **********************************************************************/
 package pthi
 
+import "net"
+
 const CERT_HASH_MAX_LENGTH = 64
 const CERT_HASH_MAX_NUMBER = 23
 const NET_TLS_CERT_PKI_MAX_SERIAL_NUMS = 3
@@ -18,9 +20,25 @@ const BIOS_VERSION_LEN = 65
 const VERSIONS_NUMBER = 50
 const UNICODE_STRING_LEN = 20
 
+const FQDN_MAX_SIZE = 256
+
 const CFG_MAX_ACL_USER_LENGTH = 33
 const CFG_MAX_ACL_PWD_LENGTH = 33
 
+// PROVISIONING_OTP_MAX_LENGTH is the longest one time password AMT accepts for provisioning
+const PROVISIONING_OTP_MAX_LENGTH = 32
+
+const CFG_PROVISIONING_MODE_NONE = 0
+const CFG_PROVISIONING_MODE_ENTERPRISE = 1
+
+const RNG_STATUS_EXIST = 0
+const RNG_STATUS_IN_PROGRESS = 1
+const RNG_STATUS_NOT_EXIST = 2
+
+const REDIRECTION_SESSION = 0
+const SYSTEM_DEFENSE = 1
+const WEB_UI = 2
+
 const PROVISIONING_MODE_REQUEST = 0x04000008
 const PROVISIONING_MODE_RESPONSE = 0x04800008
 
@@ -125,8 +143,7 @@ type Version struct {
 	MinorNumber uint8
 }
 type CommandFormat struct {
-	val    uint32
-	fields [3]uint32
+	val uint32
 }
 type MessageHeader struct {
 	Version  Version
@@ -146,20 +163,197 @@ type GetUUIDResponse struct {
 	UUID   [16]uint8
 }
 
+type GetCodeVersionsRequest struct {
+	Header MessageHeader
+}
+
+type AMTUnicodeString struct {
+	Length uint16
+	String [UNICODE_STRING_LEN]uint8
+}
+
+type AMTVersionType struct {
+	Description AMTUnicodeString
+	Version     AMTUnicodeString
+}
+
+type GetCodeVersionsResponse struct {
+	Header        ResponseMessageHeader
+	BiosVersion   [BIOS_VERSION_LEN]uint8
+	VersionsCount uint32
+	Versions      [VERSIONS_NUMBER]AMTVersionType
+}
+
+// CodeVersions holds the decoded BIOS version and firmware version table
+type CodeVersions struct {
+	BiosVersion string
+	Versions    []AMTVersion
+}
+
+// AMTVersion is a single decoded entry of the firmware version table (e.g. "AMT", "Flash", "Netstack")
+type AMTVersion struct {
+	Description string
+	Version     string
+}
+
+type GetProvisioningStateRequest struct {
+	Header MessageHeader
+}
+type GetProvisioningStateResponse struct {
+	Header            ResponseMessageHeader
+	ProvisioningState uint32
+}
+
+type GetProvisioningModeRequest struct {
+	Header MessageHeader
+}
+type GetProvisioningModeResponse struct {
+	Header           ResponseMessageHeader
+	ProvisioningMode uint32
+	LegacyMode       uint32
+}
+
+// ProvisioningMode is the decoded form of GetProvisioningModeResponse
+type ProvisioningMode struct {
+	Mode       uint32
+	LegacyMode bool
+}
+
+type GetProvisioningTLSModeRequest struct {
+	Header MessageHeader
+}
+type GetProvisioningTLSModeResponse struct {
+	Header              ResponseMessageHeader
+	ProvisioningTLSMode uint32
+}
+
+type GetZeroTouchEnabledRequest struct {
+	Header MessageHeader
+}
+type GetZeroTouchEnabledResponse struct {
+	Header           ResponseMessageHeader
+	ZeroTouchEnabled uint32
+}
+
+type GetEHBCStateRequest struct {
+	Header MessageHeader
+}
+type GetEHBCStateResponse struct {
+	Header    ResponseMessageHeader
+	EHBCState uint32
+}
+
+type GetFeaturesStateRequest struct {
+	Header    MessageHeader
+	RequestID uint32
+}
+type GetFeaturesStateResponse struct {
+	Header    ResponseMessageHeader
+	RequestID uint32
+	Data      [3]uint32 // FEATURES_STATUS_DATA, interpreted according to RequestID
+}
+
+// FeaturesState is the decoded form of the REDIRECTION_SESSION, SYSTEM_DEFENSE and WEB_UI feature requests.
+// REDIRECTION_SESSION reports open SOL and IDER sessions, not whether redirection or KVM are enabled.
+type FeaturesState struct {
+	SOLOpen                bool
+	IDEROpen               bool
+	SystemDefenseActivated bool
+	WebUIEnabled           bool
+}
+
+type GetLastHostResetReasonRequest struct {
+	Header MessageHeader
+}
+type GetLastHostResetReasonResponse struct {
+	Header                 ResponseMessageHeader
+	Reason                 uint32
+	RemoteControlTimeStamp uint32
+}
+
+// LastHostResetReason is the decoded form of GetLastHostResetReasonResponse
+type LastHostResetReason struct {
+	Reason                 uint32
+	RemoteControlTimeStamp uint32
+}
+
+type GetCurrentPowerPolicyRequest struct {
+	Header MessageHeader
+}
+type GetCurrentPowerPolicyResponse struct {
+	Header     ResponseMessageHeader
+	PolicyName string // AMT_ANSI_STRING on the wire
+}
+
+type StartConfigurationRequest struct {
+	Header MessageHeader
+}
+type StartConfigurationResponse struct {
+	Header ResponseMessageHeader
+}
+
+type StopConfigurationRequest struct {
+	Header MessageHeader
+}
+type StopConfigurationResponse struct {
+	Header ResponseMessageHeader
+}
+
+type SetProvisioningServerOTPResponse struct {
+	Header ResponseMessageHeader
+}
+
+type GenerateRNGSeedRequest struct {
+	Header MessageHeader
+}
+type GenerateRNGSeedResponse struct {
+	Header ResponseMessageHeader
+}
+
+type GetRNGSeedStatusRequest struct {
+	Header MessageHeader
+}
+type GetRNGSeedStatusResponse struct {
+	Header    ResponseMessageHeader
+	RNGStatus uint32
+}
+
+// SetEnterpriseAccessRequest tells AMT whether the host has access to the enterprise network.
+// Bit 0 of Flags is set when HostIPAddress holds an IPv6 address, otherwise it starts with an IPv4 address.
+type SetEnterpriseAccessRequest struct {
+	Header           MessageHeader
+	Flags            uint8
+	HostIPAddress    [16]uint8
+	EnterpriseAccess uint8
+}
+type SetEnterpriseAccessResponse struct {
+	Header ResponseMessageHeader
+}
+
+type UnprovisionRequest struct {
+	Header MessageHeader
+	Mode   uint32
+}
+type UnprovisionResponse struct {
+	Header ResponseMessageHeader
+}
+
 type GetControlModeRequest struct {
 	Header MessageHeader
 }
 type GetControlModeResponse struct {
 	Header ResponseMessageHeader
-	State  int
+	State  uint32
 }
+
+// LocalSystemAccount holds the null terminated credentials of the local system account.
+// The password must never be logged; call Wipe once the credentials are no longer needed.
 type LocalSystemAccount struct {
 	Username [CFG_MAX_ACL_USER_LENGTH]uint8
-	Password [CFG_MAX_ACL_USER_LENGTH]uint8
+	Password [CFG_MAX_ACL_PWD_LENGTH]uint8
 }
 type GetLocalSystemAccountResponse struct {
-	Header  MessageHeader
-	Status  uint32
+	Header  ResponseMessageHeader
 	Account LocalSystemAccount
 }
 
@@ -183,11 +377,59 @@ type GetLANInterfaceSettingsResponse struct {
 	MacAddress  [6]uint8
 }
 
+// InterfaceSettings holds the decoded settings of a wired or wireless LAN interface
+type InterfaceSettings struct {
+	IsEnabled   bool
+	IPAddress   net.IP
+	DHCPEnabled bool
+	DHCPMode    uint8
+	LinkStatus  uint8
+	MACAddress  net.HardwareAddr
+}
+
+type OpenUserInitiatedConnectionRequest struct {
+	Header MessageHeader
+}
+type OpenUserInitiatedConnectionResponse struct {
+	Header ResponseMessageHeader
+}
+
+type CloseUserInitiatedConnectionRequest struct {
+	Header MessageHeader
+}
+type CloseUserInitiatedConnectionResponse struct {
+	Header ResponseMessageHeader
+}
+
+type GetRemoteAccessConnectionStatusRequest struct {
+	Header MessageHeader
+}
+
+type GetRemoteAccessConnectionStatusResponse struct {
+	Header                        ResponseMessageHeader
+	NetworkConnectionStatus       uint32
+	RemoteAccessConnectionStatus  uint32
+	RemoteAccessConnectionTrigger uint32
+	MPSHostname                   string // AMT_ANSI_STRING on the wire
+}
+
+// RemoteAccessStatus holds the decoded CIRA connection status
+type RemoteAccessStatus struct {
+	NetworkConnectionStatus       uint32
+	RemoteAccessConnectionStatus  uint32
+	RemoteAccessConnectionTrigger uint32
+	MPSHostname                   string
+}
+
 type AMTHashHandles struct {
 	Length  uint32
 	Handles [CERT_HASH_MAX_NUMBER]uint32
 }
 
+type EnumerateHashHandlesRequest struct {
+	Header MessageHeader
+}
+
 type GetHashHandlesResponse struct {
 	Header      ResponseMessageHeader
 	HashHandles AMTHashHandles
@@ -208,5 +450,77 @@ type CertHashEntry struct {
 	IsActive        uint32
 	CertificateHash [CERT_HASH_MAX_LENGTH]uint8
 	HashAlgorithm   uint8
-	// Name            AMT_ANSI_STRING
+	Name            string // AMT_ANSI_STRING on the wire
+}
+
+type SetDNSSuffixResponse struct {
+	Header ResponseMessageHeader
+}
+
+// SetHostFQDNRequest is sent whole, but its header length only counts the characters of FQDN in use
+type SetHostFQDNRequest struct {
+	Header     MessageHeader
+	FQDNLength uint16
+	FQDN       [FQDN_MAX_SIZE]uint8
+}
+type SetHostFQDNResponse struct {
+	Header ResponseMessageHeader
+}
+
+type GetMACAddressesRequest struct {
+	Header MessageHeader
+}
+type GetMACAddressesResponse struct {
+	Header       ResponseMessageHeader
+	DedicatedMac [6]uint8
+	HostMac      [6]uint8
+}
+
+// MACAddresses holds the MAC address dedicated to AMT and the one it shares with the host
+type MACAddresses struct {
+	DedicatedMAC net.HardwareAddr
+	HostMAC      net.HardwareAddr
+}
+
+type GetPKIFQDNSuffixRequest struct {
+	Header MessageHeader
+}
+
+type GetPKIFQDNSuffixResponse struct {
+	Header ResponseMessageHeader
+	Suffix string // AMT_ANSI_STRING on the wire
+}
+
+type GetDNSSuffixListRequest struct {
+	Header MessageHeader
+}
+
+type GetDNSSuffixListResponse struct {
+	Header     ResponseMessageHeader
+	DataLength uint16
+	Data       []string // null separated list on the wire
+}
+
+type GetFQDNRequest struct {
+	Header MessageHeader
+}
+
+type GetFQDNResponse struct {
+	Header                     ResponseMessageHeader
+	SharedFQDN                 uint32
+	DDNSUpdateEnabled          uint32
+	DDNSPeriodicUpdateInterval uint32
+	DDNSTTL                    uint32
+	HostNameLength             uint32
+	FQDN                       string // HostNameLength characters on the wire
+}
+
+// FQDN holds the decoded host FQDN settings of AMT
+type FQDN struct {
+	FQDN                       string
+	SharedFQDN                 bool
+	DDNSUpdateEnabled          bool
+	DDNSPeriodicUpdateInterval uint32
+	DDNSTTL                    uint32
+	HostNameLength             uint32
 }
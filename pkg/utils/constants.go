@@ -20,4 +20,17 @@ const (
 
 	// MPSServerMaxLength is the max length of the servername
 	MPSServerMaxLength = 256
+
+	// ExitAMTUnavailable is the exit code when AMT cannot be accessed for an unclassified reason
+	ExitAMTUnavailable = 1
+	// ExitMEIDeviceMissing is the exit code when the MEI device node does not exist
+	ExitMEIDeviceMissing = 10
+	// ExitMEIDriverMissing is the exit code when the mei_me kernel module is not loaded
+	ExitMEIDriverMissing = 11
+	// ExitMEIAccessDenied is the exit code when the user may not open the MEI device
+	ExitMEIAccessDenied = 12
+	// ExitAMTHIMissing is the exit code when the MEI device does not expose the AMTHI client
+	ExitAMTHIMissing = 13
+	// ExitMEIBusy is the exit code when the MEI device is in use by another process
+	ExitMEIBusy = 14
 )
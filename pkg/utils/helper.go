@@ -76,3 +76,87 @@ func InterpretRemoteAccessTrigger(status int) string {
 		return "unknown"
 	}
 }
+func InterpretProvisioningState(state int) string {
+	switch state {
+	case 0:
+		return "pre-provisioning"
+	case 1:
+		return "in-provisioning"
+	case 2:
+		return "post-provisioning"
+	default:
+		return "unknown"
+	}
+}
+func InterpretProvisioningMode(mode int) string {
+	switch mode {
+	case 0:
+		return "none"
+	case 1:
+		return "enterprise"
+	case 2:
+		return "small business"
+	case 3:
+		return "remote assistance"
+	default:
+		return "unknown"
+	}
+}
+func InterpretProvisioningTLSMode(mode int) string {
+	switch mode {
+	case 0:
+		return "not ready"
+	case 1:
+		return "PSK"
+	case 2:
+		return "PKI"
+	default:
+		return "unknown"
+	}
+}
+func InterpretFeatureState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+func InterpretEHBCState(enabled bool) string {
+	if enabled {
+		return "allowed"
+	}
+	return "not allowed"
+}
+func InterpretSessionState(open bool) string {
+	if open {
+		return "open"
+	}
+	return "closed"
+}
+func InterpretLastHostResetReason(reason int) string {
+	switch reason {
+	case 0:
+		return "remote control"
+	case 1:
+		return "other"
+	default:
+		return "unknown"
+	}
+}
+func InterpretRNGSeedStatus(status int) string {
+	switch status {
+	case 0:
+		return "exists"
+	case 1:
+		return "in progress"
+	case 2:
+		return "does not exist"
+	default:
+		return "unknown"
+	}
+}
+func InterpretPowerPolicy(policyName string) string {
+	if policyName == "" {
+		return "unknown"
+	}
+	return policyName
+}
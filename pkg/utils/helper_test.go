@@ -109,3 +109,124 @@ func TestInterpretRemoteAccessConnectionStatus3(t *testing.T) {
 	result := InterpretRemoteAccessConnectionStatus(3)
 	assert.Equal(t, "unknown", result)
 }
+
+func TestInterpretProvisioningState0(t *testing.T) {
+	result := InterpretProvisioningState(0)
+	assert.Equal(t, "pre-provisioning", result)
+}
+func TestInterpretProvisioningState1(t *testing.T) {
+	result := InterpretProvisioningState(1)
+	assert.Equal(t, "in-provisioning", result)
+}
+func TestInterpretProvisioningState2(t *testing.T) {
+	result := InterpretProvisioningState(2)
+	assert.Equal(t, "post-provisioning", result)
+}
+func TestInterpretProvisioningState3(t *testing.T) {
+	result := InterpretProvisioningState(3)
+	assert.Equal(t, "unknown", result)
+}
+
+func TestInterpretProvisioningMode0(t *testing.T) {
+	result := InterpretProvisioningMode(0)
+	assert.Equal(t, "none", result)
+}
+func TestInterpretProvisioningMode1(t *testing.T) {
+	result := InterpretProvisioningMode(1)
+	assert.Equal(t, "enterprise", result)
+}
+func TestInterpretProvisioningMode2(t *testing.T) {
+	result := InterpretProvisioningMode(2)
+	assert.Equal(t, "small business", result)
+}
+func TestInterpretProvisioningMode3(t *testing.T) {
+	result := InterpretProvisioningMode(3)
+	assert.Equal(t, "remote assistance", result)
+}
+func TestInterpretProvisioningMode4(t *testing.T) {
+	result := InterpretProvisioningMode(4)
+	assert.Equal(t, "unknown", result)
+}
+
+func TestInterpretProvisioningTLSMode0(t *testing.T) {
+	result := InterpretProvisioningTLSMode(0)
+	assert.Equal(t, "not ready", result)
+}
+func TestInterpretProvisioningTLSMode1(t *testing.T) {
+	result := InterpretProvisioningTLSMode(1)
+	assert.Equal(t, "PSK", result)
+}
+func TestInterpretProvisioningTLSMode2(t *testing.T) {
+	result := InterpretProvisioningTLSMode(2)
+	assert.Equal(t, "PKI", result)
+}
+func TestInterpretProvisioningTLSMode3(t *testing.T) {
+	result := InterpretProvisioningTLSMode(3)
+	assert.Equal(t, "unknown", result)
+}
+
+func TestInterpretFeatureStateEnabled(t *testing.T) {
+	result := InterpretFeatureState(true)
+	assert.Equal(t, "enabled", result)
+}
+func TestInterpretFeatureStateDisabled(t *testing.T) {
+	result := InterpretFeatureState(false)
+	assert.Equal(t, "disabled", result)
+}
+
+func TestInterpretEHBCStateEnabled(t *testing.T) {
+	result := InterpretEHBCState(true)
+	assert.Equal(t, "allowed", result)
+}
+func TestInterpretEHBCStateDisabled(t *testing.T) {
+	result := InterpretEHBCState(false)
+	assert.Equal(t, "not allowed", result)
+}
+
+func TestInterpretSessionStateOpen(t *testing.T) {
+	result := InterpretSessionState(true)
+	assert.Equal(t, "open", result)
+}
+func TestInterpretSessionStateClosed(t *testing.T) {
+	result := InterpretSessionState(false)
+	assert.Equal(t, "closed", result)
+}
+
+func TestInterpretLastHostResetReason0(t *testing.T) {
+	result := InterpretLastHostResetReason(0)
+	assert.Equal(t, "remote control", result)
+}
+func TestInterpretLastHostResetReason1(t *testing.T) {
+	result := InterpretLastHostResetReason(1)
+	assert.Equal(t, "other", result)
+}
+func TestInterpretLastHostResetReason2(t *testing.T) {
+	result := InterpretLastHostResetReason(2)
+	assert.Equal(t, "unknown", result)
+}
+
+func TestInterpretRNGSeedStatus0(t *testing.T) {
+	result := InterpretRNGSeedStatus(0)
+	assert.Equal(t, "exists", result)
+}
+func TestInterpretRNGSeedStatus1(t *testing.T) {
+	result := InterpretRNGSeedStatus(1)
+	assert.Equal(t, "in progress", result)
+}
+func TestInterpretRNGSeedStatus2(t *testing.T) {
+	result := InterpretRNGSeedStatus(2)
+	assert.Equal(t, "does not exist", result)
+}
+func TestInterpretRNGSeedStatus3(t *testing.T) {
+	result := InterpretRNGSeedStatus(3)
+	assert.Equal(t, "unknown", result)
+}
+
+func TestInterpretPowerPolicy(t *testing.T) {
+	result := InterpretPowerPolicy("Desktop: ON in S0")
+	assert.Equal(t, "Desktop: ON in S0", result)
+}
+func TestInterpretPowerPolicyEmpty(t *testing.T) {
+	result := InterpretPowerPolicy("")
+	assert.Equal(t, "unknown", result)
+}